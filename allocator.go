@@ -0,0 +1,144 @@
+package snowFlake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+// Allocator 外部的 workerId 租约分配器, 例如 leaseServer.Client
+// worker 启动时 Acquire, 每隔 TTL/3 续约, Close 时释放; stamp 为已发出ID的最大时间戳(毫秒)
+type Allocator interface {
+	Acquire(ctx context.Context) (Lease, error)
+	// Renew 租约已失效时返回 common.LeaseConflictErr
+	Renew(ctx context.Context, lease Lease, stamp int64) (Lease, error)
+	Release(ctx context.Context, lease Lease, stamp int64) error
+}
+
+// Lease 一次 workerId 租约
+type Lease struct {
+	ID           string
+	WorkerID     int64
+	DataCenterID int64
+	TTL          time.Duration
+	// LastStamp 该 workerId 上一个持有者已发出ID的最大时间戳(毫秒), 时钟追上之前不发号
+	LastStamp int64
+}
+
+// leaseRequestTimeout 启动时申请和关闭时释放租约的超时
+const leaseRequestTimeout = 10 * time.Second
+
+// WithAllocator 从租约服务获取 workerId 和 dataCenterId, 设置后忽略 center 和数据中心选项
+// 续约失败时 worker 在本地计算的租约到期后停止发号, 租约被服务端回收后不再恢复
+func WithAllocator(a Allocator) WorkerOptFunc {
+	return func(opt *workerOpt) {
+		opt.allocator = a
+	}
+}
+
+func newLeasedWorker(opt *workerOpt) (*SfWorker, error) {
+	w := &SfWorker{
+		ServerType:  common.ServerTypeLease,
+		stopCh:      make(chan struct{}),
+		logger:      opt.logger,
+		epoch:       opt.epoch,
+		maxRollback: opt.maxRollback,
+		allocator:   opt.allocator,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), leaseRequestTimeout)
+	defer cancel()
+	sent := w.getMilliSeconds()
+	lease, err := w.allocator.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if lease.WorkerID < 0 || lease.WorkerID > common.MaxWorkerID ||
+		lease.DataCenterID < 0 || lease.DataCenterID > common.MaxDataCenterID || lease.TTL <= 0 {
+		return nil, common.OpErr.WithTrueErr(fmt.Errorf("invalid lease %+v", lease))
+	}
+	w.workerID = lease.WorkerID
+	w.dataCenterID = lease.DataCenterID
+	w.setLease(lease, sent)
+	if now := w.getMilliSeconds(); lease.LastStamp > now {
+		w.log().WarningF("clock is behind last stamp of worker %d by %d ms, refuse to generate until then", lease.WorkerID, lease.LastStamp-now)
+		w.lastStamp = lease.LastStamp
+	}
+	w.log().Info("worker lease acquired", "workerId", lease.WorkerID, "dataCenterId", lease.DataCenterID, "ttl", lease.TTL)
+
+	if err = w.loadHighWaterMark(opt.hwmPath, opt.hwmReserve); err != nil {
+		w.releaseLease()
+		return nil, err
+	}
+	go w.renewLease()
+	if opt.watchConfig {
+		w.watchConfig()
+	}
+	return w, nil
+}
+
+// setLease 以发出请求的时间计算到期时间, 比服务端的到期时间早, 调用方不需要持有 w.mu
+func (w *SfWorker) setLease(lease Lease, sent int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lease = lease
+	w.leasedAt = sent
+	w.leaseExpires = sent + lease.TTL.Milliseconds()
+}
+
+// leaseExpired now 是否已过租约模式或降级模式下本地计算的到期时间, 调用方持有 w.mu
+func (w *SfWorker) leaseExpired(now int64) bool {
+	return w.leaseExpires > 0 && now >= w.leaseExpires
+}
+
+// renewLease 每隔 TTL/3 续约, 租约被服务端回收后停止发号
+func (w *SfWorker) renewLease() {
+	for {
+		w.mu.Lock()
+		lease := w.lease
+		w.mu.Unlock()
+		interval := lease.TTL / 3
+		select {
+		case <-w.stopCh:
+			return
+		case <-time.After(interval):
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		sent := w.getMilliSeconds()
+		renewed, err := w.allocator.Renew(ctx, lease, w.heartbeatStamp())
+		cancel()
+		if errors.Is(err, common.LeaseConflictErr) {
+			w.log().Warning("worker lease revoked, stop generating", "workerId", lease.WorkerID, "err", err)
+			w.mu.Lock()
+			w.leaseLost = true
+			w.mu.Unlock()
+			leaseLostTotal.Add(1)
+			return
+		}
+		if err != nil {
+			w.log().Warning("renew worker lease fail", "workerId", lease.WorkerID, "err", err)
+			continue
+		}
+		w.setLease(renewed, sent)
+	}
+}
+
+// releaseLease 上报最终时间戳并释放租约, 租约已失效时不再释放
+func (w *SfWorker) releaseLease() error {
+	w.mu.Lock()
+	lease, lost := w.lease, w.leaseLost
+	w.mu.Unlock()
+	if lost {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), leaseRequestTimeout)
+	defer cancel()
+	err := w.allocator.Release(ctx, lease, w.heartbeatStamp())
+	if err != nil {
+		w.log().Warning("release worker lease fail", "workerId", lease.WorkerID, "err", err)
+	}
+	return err
+}
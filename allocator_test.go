@@ -0,0 +1,108 @@
+package snowFlake
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+// fakeAllocator 续约可以被阻断, 用于模拟租约服务不可用
+type fakeAllocator struct {
+	mu        sync.Mutex
+	lease     Lease
+	renewErr  error
+	renewals  int
+	released  bool
+	lastStamp int64
+}
+
+func (a *fakeAllocator) Acquire(context.Context) (Lease, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.lease, nil
+}
+
+func (a *fakeAllocator) Renew(_ context.Context, l Lease, stamp int64) (Lease, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.renewErr != nil {
+		return Lease{}, a.renewErr
+	}
+	a.renewals++
+	a.lastStamp = stamp
+	return l, nil
+}
+
+func (a *fakeAllocator) Release(_ context.Context, _ Lease, stamp int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.released = true
+	a.lastStamp = stamp
+	return nil
+}
+
+func TestSfWorker_leaseExpires(t *testing.T) {
+	a := &fakeAllocator{lease: Lease{ID: "l1", WorkerID: 7, DataCenterID: 2, TTL: 150 * time.Millisecond}}
+	sf, err := NewWorker(WithAllocator(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sf.Close(context.Background())
+	if st := sf.Status(); st.WorkerID != 7 || st.DataCenterID != 2 {
+		t.Fatalf("Status: %+v", st)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	if _, err = sf.NextID(); err != nil {
+		t.Fatalf("NextID while renewing: %v", err)
+	}
+
+	// 服务不可用时在本地到期后停止发号
+	a.mu.Lock()
+	a.renewErr = errors.New("unavailable")
+	a.mu.Unlock()
+	time.Sleep(300 * time.Millisecond)
+	if _, err = sf.NextID(); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("NextID after lease expired: %v", err)
+	}
+	if !sf.Status().LeaseLost {
+		t.Fatal("Status should report the expired lease")
+	}
+
+	// 服务恢复后续约成功即可继续发号
+	a.mu.Lock()
+	a.renewErr = nil
+	a.mu.Unlock()
+	time.Sleep(150 * time.Millisecond)
+	if _, err = sf.NextID(); err != nil {
+		t.Fatalf("NextID after renewal recovered: %v", err)
+	}
+}
+
+func TestSfWorker_leaseLastStamp(t *testing.T) {
+	last := time.Now().Add(time.Hour).UnixNano() / 1e6
+	a := &fakeAllocator{lease: Lease{ID: "l1", WorkerID: 1, TTL: time.Minute, LastStamp: last}}
+	sf, err := NewWorker(WithAllocator(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 上一个持有者的时间戳在未来, 时钟追上之前拒绝发号
+	if _, err = sf.NextID(); !errors.Is(err, ErrClockBackwards) {
+		t.Fatalf("NextID before last stamp: %v", err)
+	}
+	if err = sf.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !a.released || a.lastStamp < last {
+		t.Fatalf("Close should release with the final stamp: %+v", a)
+	}
+
+	bad := &fakeAllocator{lease: Lease{ID: "l2", WorkerID: common.MaxWorkerID + 1, TTL: time.Minute}}
+	if _, err = NewWorker(WithAllocator(bad)); err == nil {
+		t.Fatal("out of range workerId should be rejected")
+	}
+}
@@ -1,10 +1,14 @@
 package base
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -24,74 +28,385 @@ var levelMap = map[Level]string{
 	ERROR:   "ERROR",
 }
 
+func (l Level) String() string {
+	return levelMap[l]
+}
+
+// ParseLevel 解析日志级别, 不区分大小写, 无法识别时返回 INFO
+func ParseLevel(s string) Level {
+	for level, name := range levelMap {
+		if strings.EqualFold(s, name) {
+			return level
+		}
+	}
+	if strings.EqualFold(s, "WARN") {
+		return WARNING
+	}
+	return INFO
+}
+
+// Logger 日志接口
+// xxxF 为格式化输出; Debug/Info/Warning/Error 为结构化输出, kv 为交替的键值对
+// 任何级别都不会退出进程
 type Logger interface {
 	DebugF(string, ...interface{})
 	InfoF(string, ...interface{})
 	WarningF(string, ...interface{})
 	ErrorF(string, ...interface{})
 
-	log(Level, string, ...interface{})
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Warning(msg string, kv ...interface{})
+	Error(msg string, kv ...interface{})
+
+	// With 返回附带固定键值对的 Logger
+	With(kv ...interface{}) Logger
+	Enabled(Level) bool
+}
+
+// Format 日志输出格式
+type Format int8
+
+const (
+	FormatText Format = iota
+	FormatJSON        // 每行一个JSON对象, 便于日志采集
+)
+
+// ParseFormat 解析日志格式, "json" 以外都视为文本格式
+func ParseFormat(s string) Format {
+	if strings.EqualFold(s, "json") {
+		return FormatJSON
+	}
+	return FormatText
+}
+
+type LoggerOptFunc func(l *defaultLogger)
+
+// WithFormat 指定输出格式, 默认为文本格式
+func WithFormat(format Format) LoggerOptFunc {
+	return func(l *defaultLogger) {
+		l.format = format
+	}
 }
 
 func DefaultLogger() Logger {
-	return new(defaultLogger)
+	return NewLogger(os.Stdout, DEBUG)
+}
+
+// NewLogger 输出到 w, 低于 level 的日志被丢弃
+func NewLogger(w io.Writer, level Level, ofs ...LoggerOptFunc) Logger {
+	l := &defaultLogger{out: &syncWriter{w: w}, level: level}
+	for _, op := range ofs {
+		op(l)
+	}
+	return l
+}
+
+// DLogger 包级别的默认 Logger, 每次输出时转发给 SetLogger 设置的 Logger; 只读, 替换请使用 SetLogger
+var DLogger Logger = globalLogger{}
+
+// loggerHolder 全局 Logger, 每次输出期间持有 mu 的读锁
+type loggerHolder struct {
+	mu      sync.RWMutex
+	l       Logger
+	retired bool // 已被 SetLogger 替换, 持有写锁时设置
+}
+
+// acquireLogger 返回当前的全局 Logger 并持有其读锁; 读到的已被替换时重新读取
+func acquireLogger() *loggerHolder {
+	for {
+		h := current.Load()
+		h.mu.RLock()
+		if !h.retired {
+			return h
+		}
+		h.mu.RUnlock()
+	}
+}
+
+var current atomic.Pointer[loggerHolder]
+
+func init() {
+	current.Store(&loggerHolder{l: DefaultLogger()})
 }
 
-var DLogger = DefaultLogger()
+// SetLogger 替换包级别的默认 Logger, 等待经由旧 Logger 进行中的输出结束后返回,
+// 返回后可以安全地关闭旧 Logger 的输出
+func SetLogger(l Logger) {
+	if g, ok := l.(globalLogger); ok {
+		// DLogger 本身转发给当前的 Logger, 按当前的 Logger 处理
+		l = g.with(GetLogger())
+	}
+	if l == nil {
+		l = DefaultLogger()
+	}
+	old := current.Swap(&loggerHolder{l: l})
+	old.mu.Lock()
+	old.retired = true
+	old.mu.Unlock()
+}
+
+// GetLogger 返回 SetLogger 设置的 Logger, 用于保存后恢复; 输出请使用 DLogger 或包级别函数
+func GetLogger() Logger {
+	return current.Load().l
+}
+
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
 
-type defaultLogger struct{}
+type defaultLogger struct {
+	out    io.Writer
+	level  Level
+	format Format
+	kv     []interface{}
+}
 
-func (d *defaultLogger) log(level Level, format string, a ...interface{}) {
-	_, file, line, _ := runtime.Caller(2)
+// log depth 为调用方相对 log 的栈深度
+func (d *defaultLogger) log(depth int, level Level, msg string, kv ...interface{}) {
+	if !d.Enabled(level) {
+		return
+	}
+	_, file, line, _ := runtime.Caller(depth)
 	sections := strings.Split(file, "/")
 	file = sections[len(sections)-1]
-	s := fmt.Sprintf("%s %s:%d %s %s", time.Now().String()[:19], file, line, levelMap[level], fmt.Sprintf(format, a...))
-	fmt.Println(s)
-	if level > WARNING {
-		os.Exit(-1)
+
+	var b strings.Builder
+	if d.format == FormatJSON {
+		b.WriteByte('{')
+		writeJSON(&b, "time", time.Now().Format(time.RFC3339Nano))
+		b.WriteByte(',')
+		writeJSON(&b, "level", levelMap[level])
+		b.WriteByte(',')
+		writeJSON(&b, "caller", fmt.Sprintf("%s:%d", file, line))
+		b.WriteByte(',')
+		writeJSON(&b, "msg", msg)
+		writeJSONKV(&b, d.kv)
+		writeJSONKV(&b, kv)
+		b.WriteString("}\n")
+		io.WriteString(d.out, b.String())
+		return
+	}
+	fmt.Fprintf(&b, "%s %s:%d %s %s", time.Now().String()[:19], file, line, levelMap[level], msg)
+	writeKV(&b, d.kv)
+	writeKV(&b, kv)
+	b.WriteByte('\n')
+	io.WriteString(d.out, b.String())
+}
+
+func writeJSONKV(b *strings.Builder, kv []interface{}) {
+	for i := 0; i < len(kv); i += 2 {
+		b.WriteByte(',')
+		if i+1 < len(kv) {
+			writeJSON(b, fmt.Sprint(kv[i]), kv[i+1])
+		} else {
+			writeJSON(b, "!BADKEY", kv[i])
+		}
+	}
+}
+
+// writeJSON 写入 "key":value; error 和 fmt.Stringer 按字符串输出, 无法序列化的值按 %v 输出
+func writeJSON(b *strings.Builder, key string, v interface{}) {
+	k, _ := json.Marshal(key)
+	b.Write(k)
+	b.WriteByte(':')
+	switch val := v.(type) {
+	case error:
+		v = val.Error()
+	case fmt.Stringer:
+		v = val.String()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprintf("%+v", v))
+	}
+	b.Write(data)
+}
+
+func writeKV(b *strings.Builder, kv []interface{}) {
+	for i := 0; i < len(kv); i += 2 {
+		if i+1 < len(kv) {
+			fmt.Fprintf(b, " %v=%v", kv[i], kv[i+1])
+		} else {
+			fmt.Fprintf(b, " %v", kv[i])
+		}
 	}
 }
 
+func (d *defaultLogger) Enabled(level Level) bool {
+	return level >= d.level
+}
+
+func (d *defaultLogger) With(kv ...interface{}) Logger {
+	merged := make([]interface{}, 0, len(d.kv)+len(kv))
+	merged = append(merged, d.kv...)
+	merged = append(merged, kv...)
+	return &defaultLogger{out: d.out, level: d.level, format: d.format, kv: merged}
+}
+
 func (d *defaultLogger) DebugF(format string, a ...interface{}) {
-	d.log(DEBUG, format, a...)
+	d.log(2, DEBUG, fmt.Sprintf(format, a...))
 }
 
 func (d *defaultLogger) InfoF(format string, a ...interface{}) {
-	d.log(INFO, format, a...)
+	d.log(2, INFO, fmt.Sprintf(format, a...))
 }
 
 func (d *defaultLogger) WarningF(format string, a ...interface{}) {
-	d.log(WARNING, format, a...)
+	d.log(2, WARNING, fmt.Sprintf(format, a...))
 }
 
 func (d *defaultLogger) ErrorF(format string, a ...interface{}) {
-	d.log(ERROR, format, a...)
+	d.log(2, ERROR, fmt.Sprintf(format, a...))
 }
 
-func DebugF(format string, a ...interface{}) {
-	if DLogger == nil {
-		DLogger = DefaultLogger()
+func (d *defaultLogger) Debug(msg string, kv ...interface{}) {
+	d.log(2, DEBUG, msg, kv...)
+}
+
+func (d *defaultLogger) Info(msg string, kv ...interface{}) {
+	d.log(2, INFO, msg, kv...)
+}
+
+func (d *defaultLogger) Warning(msg string, kv ...interface{}) {
+	d.log(2, WARNING, msg, kv...)
+}
+
+func (d *defaultLogger) Error(msg string, kv ...interface{}) {
+	d.log(2, ERROR, msg, kv...)
+}
+
+// logF 包级别函数的输出, 默认 Logger 多跳过一层栈以打印真实的调用位置
+func logF(level Level, format string, a ...interface{}) {
+	h := acquireLogger()
+	defer h.mu.RUnlock()
+
+	l := h.l
+	if d, ok := l.(*defaultLogger); ok {
+		d.log(3, level, fmt.Sprintf(format, a...))
+		return
+	}
+	switch level {
+	case DEBUG:
+		l.DebugF(format, a...)
+	case INFO:
+		l.InfoF(format, a...)
+	case WARNING:
+		l.WarningF(format, a...)
+	default:
+		l.ErrorF(format, a...)
 	}
-	DLogger.DebugF(format, a)
+}
+
+func DebugF(format string, a ...interface{}) {
+	logF(DEBUG, format, a...)
 }
 
 func InfoF(format string, a ...interface{}) {
-	if DLogger == nil {
-		DLogger = DefaultLogger()
-	}
-	DLogger.InfoF(format, a)
+	logF(INFO, format, a...)
 }
 
 func WarningF(format string, a ...interface{}) {
-	if DLogger == nil {
-		DLogger = DefaultLogger()
-	}
-	DLogger.WarningF(format, a)
+	logF(WARNING, format, a...)
 }
 
 func ErrorF(format string, a ...interface{}) {
-	if DLogger == nil {
-		DLogger = DefaultLogger()
+	logF(ERROR, format, a...)
+}
+
+// OrDefault l 为 nil 时返回包级别的默认 Logger, 供各组件注入自定义 Logger 时使用
+func OrDefault(l Logger) Logger {
+	if l == nil {
+		return DLogger
+	}
+	return l
+}
+
+// globalLogger DLogger 的实现, 不持有被 SetLogger 替换掉的 Logger
+type globalLogger struct {
+	kv []interface{}
+}
+
+func (g globalLogger) with(l Logger) Logger {
+	if len(g.kv) == 0 {
+		return l
 	}
-	DLogger.ErrorF(format, a)
+	return l.With(g.kv...)
+}
+
+// output 调用方为 globalLogger 的导出方法, 默认 Logger 多跳过一层栈以打印真实的调用位置
+func (g globalLogger) output(level Level, msg string, kv []interface{}) {
+	h := acquireLogger()
+	defer h.mu.RUnlock()
+
+	if d, ok := h.l.(*defaultLogger); ok {
+		if len(g.kv) > 0 {
+			kv = append(append([]interface{}{}, g.kv...), kv...)
+		}
+		d.log(3, level, msg, kv...)
+		return
+	}
+	l := g.with(h.l)
+	switch level {
+	case DEBUG:
+		l.Debug(msg, kv...)
+	case INFO:
+		l.Info(msg, kv...)
+	case WARNING:
+		l.Warning(msg, kv...)
+	default:
+		l.Error(msg, kv...)
+	}
+}
+
+func (g globalLogger) Enabled(level Level) bool {
+	h := acquireLogger()
+	defer h.mu.RUnlock()
+	return h.l.Enabled(level)
+}
+
+func (g globalLogger) With(kv ...interface{}) Logger {
+	merged := make([]interface{}, 0, len(g.kv)+len(kv))
+	merged = append(merged, g.kv...)
+	merged = append(merged, kv...)
+	return globalLogger{kv: merged}
+}
+
+func (g globalLogger) DebugF(format string, a ...interface{}) {
+	g.output(DEBUG, fmt.Sprintf(format, a...), nil)
+}
+
+func (g globalLogger) InfoF(format string, a ...interface{}) {
+	g.output(INFO, fmt.Sprintf(format, a...), nil)
+}
+
+func (g globalLogger) WarningF(format string, a ...interface{}) {
+	g.output(WARNING, fmt.Sprintf(format, a...), nil)
+}
+
+func (g globalLogger) ErrorF(format string, a ...interface{}) {
+	g.output(ERROR, fmt.Sprintf(format, a...), nil)
+}
+
+func (g globalLogger) Debug(msg string, kv ...interface{}) {
+	g.output(DEBUG, msg, kv)
+}
+
+func (g globalLogger) Info(msg string, kv ...interface{}) {
+	g.output(INFO, msg, kv)
+}
+
+func (g globalLogger) Warning(msg string, kv ...interface{}) {
+	g.output(WARNING, msg, kv)
+}
+
+func (g globalLogger) Error(msg string, kv ...interface{}) {
+	g.output(ERROR, msg, kv)
 }
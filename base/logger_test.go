@@ -1,9 +1,132 @@
 package base
 
-import "testing"
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
 
 func TestDebugF(t *testing.T) {
 	logger := DefaultLogger()
 	s := "127.0.0.1"
 	logger.DebugF("server %s error!", s)
 }
+
+func TestLogger_level(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewLogger(buf, WARNING)
+	logger.InfoF("dropped %d", 1)
+	logger.ErrorF("kept %d", 2)
+	out := buf.String()
+	if strings.Contains(out, "dropped") {
+		t.Fatalf("info should be filtered: %q", out)
+	}
+	if !strings.Contains(out, "ERROR kept 2") {
+		t.Fatalf("error not logged: %q", out)
+	}
+}
+
+func TestLogger_kv(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewLogger(buf, DEBUG).With("workerId", 7)
+	logger.Warning("released", "path", "/IDMaker/Id-7")
+	out := buf.String()
+	if !strings.Contains(out, "logger_test.go") {
+		t.Fatalf("caller missing: %q", out)
+	}
+	if !strings.Contains(out, "WARNING released workerId=7 path=/IDMaker/Id-7") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestPackageLevel(t *testing.T) {
+	buf := &bytes.Buffer{}
+	old := GetLogger()
+	SetLogger(NewLogger(buf, DEBUG))
+	defer SetLogger(old)
+
+	ErrorF("server %s port %d", "127.0.0.1", 2181)
+	out := buf.String()
+	if !strings.Contains(out, "server 127.0.0.1 port 2181") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	if !strings.Contains(out, "logger_test.go") {
+		t.Fatalf("caller missing: %q", out)
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	logger.DebugF("dropped")
+	logger.With("workerId", 7).Error("released", "dataCenterId", 1)
+	out := buf.String()
+	if strings.Contains(out, "dropped") {
+		t.Fatalf("debug should be filtered: %q", out)
+	}
+	if !strings.Contains(out, "level=ERROR msg=released workerId=7 dataCenterId=1") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+// closedWriter 写入过程中或关闭后写入时报错
+type closedWriter struct {
+	t      *testing.T
+	closed atomic.Bool
+}
+
+func (w *closedWriter) Write(p []byte) (int, error) {
+	if w.closed.Load() {
+		w.t.Errorf("write after close: %q", p)
+	}
+	time.Sleep(100 * time.Microsecond)
+	if w.closed.Load() {
+		w.t.Errorf("closed during write: %q", p)
+	}
+	return len(p), nil
+}
+
+func (w *closedWriter) Close() {
+	w.closed.Store(true)
+}
+
+func TestSetLogger_concurrent(t *testing.T) {
+	old := GetLogger()
+	defer SetLogger(old)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l := DLogger.With("worker", 1)
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				InfoF("package level")
+				l.Info("structured")
+				runtime.Gosched()
+			}
+		}()
+	}
+	// 替换后立即关闭旧的输出, 不应再有写入
+	for i := 0; i < 20; i++ {
+		w := &closedWriter{t: t}
+		SetLogger(NewLogger(w, DEBUG))
+		time.Sleep(time.Millisecond)
+		SetLogger(NewLogger(io.Discard, DEBUG))
+		w.Close()
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,268 @@
+package base
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "20060102T150405.000"
+
+// RotateOpt 日志文件的切分策略
+type RotateOpt struct {
+	MaxSize    int64         // 单个文件的最大字节数, 0表示不按大小切分
+	Interval   time.Duration // 按时间切分的周期, 如 24h, 0表示不按时间切分
+	MaxBackups int           // 最多保留的历史文件个数, 0表示全部保留
+	Compress   bool          // 是否gzip压缩历史文件
+}
+
+// RotateWriter 写入 path 的 io.WriteCloser, 按大小和时间切分,
+// 历史文件命名为 <name>-<时间><ext>[.gz], 与 path 位于同一目录
+type RotateWriter struct {
+	path string
+	opt  RotateOpt
+
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+	nextRotate time.Time
+	closed     bool
+
+	millCh chan struct{}
+	millWg sync.WaitGroup
+}
+
+// NewRotateWriter 打开(必要时创建) path, 写入时追加到文件末尾
+func NewRotateWriter(path string, opt RotateOpt) (*RotateWriter, error) {
+	w := &RotateWriter{
+		path:   path,
+		opt:    opt,
+		millCh: make(chan struct{}, 1),
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	w.millWg.Add(1)
+	go w.mill()
+	return w, nil
+}
+
+// Write 切分失败时继续写入当前文件, 并返回切分的错误
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	var rotateErr error
+	if w.shouldRotate(int64(len(p))) {
+		rotateErr = w.rotate()
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+// Rotate 立即切分当前文件
+func (w *RotateWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	return w.rotate()
+}
+
+// Close 关闭当前文件并等待后台的压缩和清理完成
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	err := w.file.Close()
+	close(w.millCh)
+	w.mu.Unlock()
+	w.millWg.Wait()
+	return err
+}
+
+func (w *RotateWriter) shouldRotate(n int64) bool {
+	if w.opt.MaxSize > 0 && w.size > 0 && w.size+n > w.opt.MaxSize {
+		return true
+	}
+	return w.opt.Interval > 0 && !time.Now().Before(w.nextRotate)
+}
+
+func (w *RotateWriter) open() error {
+	f, size, err := w.openFile()
+	if err != nil {
+		return err
+	}
+	w.file = f
+	w.size = size
+	w.resetNextRotate()
+	return nil
+}
+
+func (w *RotateWriter) openFile() (*os.File, int64, error) {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return nil, 0, err
+	}
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+func (w *RotateWriter) resetNextRotate() {
+	if w.opt.Interval > 0 {
+		w.nextRotate = time.Now().Truncate(w.opt.Interval).Add(w.opt.Interval)
+	}
+}
+
+// rotate 调用方持有 w.mu; 先改名再打开新文件, 任一步失败时继续使用原来的文件
+func (w *RotateWriter) rotate() error {
+	backup := w.backupName(time.Now())
+	if err := os.Rename(w.path, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	f, size, err := w.openFile()
+	if err != nil {
+		// 改回原名, 避免后台把仍在写入的文件当作历史文件压缩
+		_ = os.Rename(backup, w.path)
+		return err
+	}
+	err = w.file.Close()
+	w.file, w.size = f, size
+	w.resetNextRotate()
+	select {
+	case w.millCh <- struct{}{}:
+	default:
+	}
+	return err
+}
+
+// backupName 同一毫秒内多次切分时顺延, 避免覆盖已有的历史文件
+func (w *RotateWriter) backupName(t time.Time) string {
+	dir, prefix, ext := w.nameParts()
+	for {
+		name := filepath.Join(dir, prefix+t.Format(backupTimeFormat)+ext)
+		if !fileExists(name) && !fileExists(name+".gz") {
+			return name
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+func (w *RotateWriter) nameParts() (dir, prefix, ext string) {
+	dir = filepath.Dir(w.path)
+	name := filepath.Base(w.path)
+	ext = filepath.Ext(name)
+	return dir, strings.TrimSuffix(name, ext) + "-", ext
+}
+
+// mill 在后台压缩历史文件并清理超出保留个数的文件
+func (w *RotateWriter) mill() {
+	defer w.millWg.Done()
+	for range w.millCh {
+		w.compressAndPrune()
+	}
+}
+
+func (w *RotateWriter) compressAndPrune() {
+	backups, err := w.backups()
+	if err != nil {
+		DebugF("list log backups err:[%+v]", err)
+		return
+	}
+	if w.opt.Compress {
+		for i, name := range backups {
+			if strings.HasSuffix(name, ".gz") {
+				continue
+			}
+			if err = gzipFile(name); err != nil {
+				DebugF("gzip log backup err:[%+v], path:[%s]", err, name)
+				continue
+			}
+			backups[i] = name + ".gz"
+		}
+	}
+	if w.opt.MaxBackups <= 0 || len(backups) <= w.opt.MaxBackups {
+		return
+	}
+	for _, name := range backups[:len(backups)-w.opt.MaxBackups] {
+		_ = os.Remove(name)
+	}
+}
+
+// backups 历史文件, 按时间从旧到新排序
+func (w *RotateWriter) backups() ([]string, error) {
+	dir, prefix, ext := w.nameParts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, e := range entries {
+		name := e.Name()
+		plain := strings.TrimSuffix(name, ".gz")
+		if e.IsDir() || !strings.HasPrefix(plain, prefix) || !strings.HasSuffix(plain, ext) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(plain, prefix), ext)
+		if _, err = time.Parse(backupTimeFormat, stamp); err != nil {
+			continue
+		}
+		res = append(res, filepath.Join(dir, name))
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return strings.TrimSuffix(res[i], ".gz") < strings.TrimSuffix(res[j], ".gz")
+	})
+	return res, nil
+}
+
+// gzipFile 压缩为 name.gz 后删除原文件
+func gzipFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(name + ".gz")
+		return err
+	}
+	return os.Remove(name)
+}
@@ -0,0 +1,130 @@
+package base
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotateWriter_size(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotateWriter(filepath.Join(dir, "project.log"), RotateOpt{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err = w.Write([]byte("0123456789")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	backups, err := w.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %v", backups)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "project.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "0123456789" {
+		t.Fatalf("unexpected current file: %q", data)
+	}
+}
+
+func TestRotateWriter_compress(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotateWriter(filepath.Join(dir, "project.log"), RotateOpt{Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("rotated\n"))
+	if err = w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	backups, _ := w.backups()
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], ".gz") {
+		t.Fatalf("expected one gzip backup, got %v", backups)
+	}
+	f, err := os.Open(backups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(gz)
+	if string(data) != "rotated\n" {
+		t.Fatalf("unexpected backup content: %q", data)
+	}
+}
+
+func TestRotateWriter_rotateFail(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "log")
+	path := filepath.Join(dir, "project.log")
+	w, err := NewRotateWriter(path, RotateOpt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 目录被替换为普通文件, 无法打开新文件
+	if err = os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(dir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Rotate(); err == nil {
+		t.Fatal("rotate should fail")
+	}
+	if _, err = w.Write([]byte("kept\n")); err != nil {
+		t.Fatalf("write after a failed rotation: %v", err)
+	}
+
+	// 恢复后可以继续切分
+	os.Remove(dir)
+	os.Mkdir(dir, 0755)
+	if err = w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("reopened\n"))
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write([]byte("closed\n")); err != os.ErrClosed {
+		t.Fatalf("write after close: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "reopened\n" {
+		t.Fatalf("unexpected current file: %q", data)
+	}
+}
+
+func TestLogger_json(t *testing.T) {
+	buf := &strings.Builder{}
+	logger := NewLogger(buf, DEBUG, WithFormat(FormatJSON)).With("workerId", 7)
+	logger.Error("release fail", "err", os.ErrClosed, "path", "/IDMaker/Id-7")
+	out := buf.String()
+	for _, want := range []string{`"level":"ERROR"`, `"msg":"release fail"`, `"workerId":7`, `"err":"file already closed"`, `"path":"/IDMaker/Id-7"`} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %s in %q", want, out)
+		}
+	}
+	if !strings.HasPrefix(out, "{") || !strings.HasSuffix(out, "}\n") {
+		t.Fatalf("not a json line: %q", out)
+	}
+}
@@ -0,0 +1,81 @@
+package base
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// SlogLogger 把 *slog.Logger 适配为 Logger
+type SlogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlogLogger l 为 nil 时使用 slog.Default()
+func NewSlogLogger(l *slog.Logger) *SlogLogger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &SlogLogger{l: l}
+}
+
+func toSlogLevel(level Level) slog.Level {
+	switch level {
+	case DEBUG:
+		return slog.LevelDebug
+	case INFO:
+		return slog.LevelInfo
+	case WARNING:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+func (s *SlogLogger) Enabled(level Level) bool {
+	return s.l.Enabled(context.Background(), toSlogLevel(level))
+}
+
+func (s *SlogLogger) With(kv ...interface{}) Logger {
+	return &SlogLogger{l: s.l.With(kv...)}
+}
+
+func (s *SlogLogger) DebugF(format string, a ...interface{}) {
+	s.logF(DEBUG, format, a...)
+}
+
+func (s *SlogLogger) InfoF(format string, a ...interface{}) {
+	s.logF(INFO, format, a...)
+}
+
+func (s *SlogLogger) WarningF(format string, a ...interface{}) {
+	s.logF(WARNING, format, a...)
+}
+
+func (s *SlogLogger) ErrorF(format string, a ...interface{}) {
+	s.logF(ERROR, format, a...)
+}
+
+func (s *SlogLogger) Debug(msg string, kv ...interface{}) {
+	s.l.Debug(msg, kv...)
+}
+
+func (s *SlogLogger) Info(msg string, kv ...interface{}) {
+	s.l.Info(msg, kv...)
+}
+
+func (s *SlogLogger) Warning(msg string, kv ...interface{}) {
+	s.l.Warn(msg, kv...)
+}
+
+func (s *SlogLogger) Error(msg string, kv ...interface{}) {
+	s.l.Error(msg, kv...)
+}
+
+// logF 级别未开启时不做格式化
+func (s *SlogLogger) logF(level Level, format string, a ...interface{}) {
+	if !s.Enabled(level) {
+		return
+	}
+	s.l.Log(context.Background(), toSlogLevel(level), fmt.Sprintf(format, a...))
+}
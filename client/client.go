@@ -0,0 +1,320 @@
+// Package client 远程发号服务的客户端, 方法签名与 SfWorker 一致, 可以在内嵌发号器和远程服务之间切换
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lypee/snowFlake/base"
+	"github.com/lypee/snowFlake/common"
+)
+
+type clientOpt struct {
+	batch         int           // 每次预取的个数
+	watermark     int           // 本地缓冲低于该值时异步补充
+	timeout       time.Duration // 单次取号(含对冲和故障转移)的超时
+	hedgeDelay    time.Duration // 请求超过该时间未返回时向下一个端点发起对冲请求, 0表示不对冲
+	failThreshold int           // 连续失败该次数后端点进入冷却
+	cooldown      time.Duration // 冷却期内优先使用其他端点
+	logger        base.Logger
+}
+
+func defaultClientOpt() *clientOpt {
+	return &clientOpt{
+		batch:         1000,
+		watermark:     250,
+		timeout:       3 * time.Second,
+		failThreshold: 3,
+		cooldown:      10 * time.Second,
+	}
+}
+
+type ClientOptFunc func(opt *clientOpt)
+
+// WithPrefetch 每次预取 batch 个ID, 本地缓冲少于 watermark 时在后台补充; batch 为0表示不预取
+func WithPrefetch(batch, watermark int) ClientOptFunc {
+	return func(opt *clientOpt) {
+		opt.batch = batch
+		opt.watermark = watermark
+	}
+}
+
+// WithTimeout 单次取号的超时, 包括对冲和故障转移
+func WithTimeout(d time.Duration) ClientOptFunc {
+	return func(opt *clientOpt) {
+		opt.timeout = d
+	}
+}
+
+// WithHedgeDelay 请求超过 d 未返回时并发请求下一个端点, 先返回者胜出, 其余请求取得的ID被丢弃
+func WithHedgeDelay(d time.Duration) ClientOptFunc {
+	return func(opt *clientOpt) {
+		opt.hedgeDelay = d
+	}
+}
+
+// WithFailover 端点连续失败 threshold 次后冷却 cooldown, 冷却期内只在其他端点都不可用时使用
+func WithFailover(threshold int, cooldown time.Duration) ClientOptFunc {
+	return func(opt *clientOpt) {
+		opt.failThreshold = threshold
+		opt.cooldown = cooldown
+	}
+}
+
+func WithLogger(l base.Logger) ClientOptFunc {
+	return func(opt *clientOpt) {
+		opt.logger = l
+	}
+}
+
+// Client 远程发号客户端, 可以并发使用
+// 本地缓冲的ID来自不同端点和不同批次, 保证唯一但不保证递增
+type Client struct {
+	opt       *clientOpt
+	endpoints []*endpoint
+
+	mu        sync.Mutex
+	buf       []uint64
+	next      int // 下一次请求的起始端点
+	refilling bool
+	refilled  chan struct{} // 当前的后台补充完成时关闭
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+type endpoint struct {
+	Endpoint
+	mu        sync.Mutex
+	fails     int
+	downUntil time.Time
+}
+
+func (e *endpoint) healthy(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !now.Before(e.downUntil)
+}
+
+func (e *endpoint) succeed() {
+	e.mu.Lock()
+	e.fails = 0
+	e.downUntil = time.Time{}
+	e.mu.Unlock()
+}
+
+// fail 返回端点是否因此进入冷却
+func (e *endpoint) fail(threshold int, cooldown time.Duration) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.fails++
+	if e.fails < threshold {
+		return false
+	}
+	e.fails = 0
+	e.downUntil = time.Now().Add(cooldown)
+	return true
+}
+
+// New 创建客户端, 创建后在后台预取第一批ID
+func New(endpoints []Endpoint, ofs ...ClientOptFunc) (*Client, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("client: no endpoints")
+	}
+	opt := defaultClientOpt()
+	for _, of := range ofs {
+		of(opt)
+	}
+	if opt.batch < 0 || opt.watermark < 0 || opt.watermark > opt.batch {
+		return nil, fmt.Errorf("client: invalid prefetch %d/%d", opt.batch, opt.watermark)
+	}
+	if opt.timeout <= 0 {
+		return nil, fmt.Errorf("client: invalid timeout %v", opt.timeout)
+	}
+	if opt.failThreshold < 1 {
+		opt.failThreshold = 1
+	}
+	c := &Client{opt: opt}
+	for _, e := range endpoints {
+		c.endpoints = append(c.endpoints, &endpoint{Endpoint: e})
+	}
+	c.mu.Lock()
+	c.maybeRefill()
+	c.mu.Unlock()
+	return c, nil
+}
+
+func (c *Client) log() base.Logger {
+	return base.OrDefault(c.opt.logger)
+}
+
+func (c *Client) NextID() (uint64, error) {
+	ids, err := c.NextIDs(1)
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// NextIDs 优先从本地缓冲取, 缓冲不足且正在补充时先等待补充完成, 仍不足的部分同步向服务端请求
+func (c *Client) NextIDs(n int) ([]uint64, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("client: count must be positive, got %d", n)
+	}
+	c.mu.Lock()
+	if len(c.buf) < n && c.refilling {
+		refilled := c.refilled
+		c.mu.Unlock()
+		<-refilled
+		c.mu.Lock()
+	}
+	if c.closed {
+		c.mu.Unlock()
+		return nil, common.WorkerClosedErr
+	}
+	take := n
+	if take > len(c.buf) {
+		take = len(c.buf)
+	}
+	ids := make([]uint64, take, n)
+	copy(ids, c.buf)
+	c.buf = c.buf[take:]
+	c.maybeRefill()
+	c.mu.Unlock()
+
+	if take == n {
+		return ids, nil
+	}
+	more, err := c.fetch(n - take)
+	if err != nil {
+		// 已取出的ID放回缓冲, 不浪费
+		c.unread(ids)
+		return nil, err
+	}
+	return append(ids, more...), nil
+}
+
+// Close 停止后台预取并丢弃本地缓冲, 端点由调用方关闭
+func (c *Client) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.buf = nil
+	c.mu.Unlock()
+	c.wg.Wait()
+	return nil
+}
+
+func (c *Client) unread(ids []uint64) {
+	if len(ids) == 0 {
+		return
+	}
+	c.mu.Lock()
+	if !c.closed {
+		c.buf = append(ids, c.buf...)
+	}
+	c.mu.Unlock()
+}
+
+// maybeRefill 缓冲不高于水位时启动后台补充, 调用方持有 c.mu
+func (c *Client) maybeRefill() {
+	if c.closed || c.refilling || c.opt.batch == 0 || len(c.buf) > c.opt.watermark {
+		return
+	}
+	c.refilling = true
+	c.refilled = make(chan struct{})
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ids, err := c.fetch(c.opt.batch)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.refilling = false
+		close(c.refilled)
+		if err != nil {
+			c.log().Warning("prefetch failed", "err", err)
+			return
+		}
+		if !c.closed {
+			c.buf = append(c.buf, ids...)
+		}
+	}()
+}
+
+// order 本次请求的端点顺序: 从轮转位置开始, 健康的端点在前, 冷却中的在后
+func (c *Client) order() []*endpoint {
+	c.mu.Lock()
+	start := c.next
+	c.next = (c.next + 1) % len(c.endpoints)
+	c.mu.Unlock()
+
+	now := time.Now()
+	healthy := make([]*endpoint, 0, len(c.endpoints))
+	var down []*endpoint
+	for i := range c.endpoints {
+		e := c.endpoints[(start+i)%len(c.endpoints)]
+		if e.healthy(now) {
+			healthy = append(healthy, e)
+		} else {
+			down = append(down, e)
+		}
+	}
+	return append(healthy, down...)
+}
+
+type fetchResult struct {
+	e   *endpoint
+	ids []uint64
+	err error
+}
+
+// fetch 向端点请求 n 个ID: 失败时立即转向下一个端点, 超过 hedgeDelay 未返回时并发请求下一个端点
+func (c *Client) fetch(n int) ([]uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.opt.timeout)
+	defer cancel()
+
+	eps := c.order()
+	results := make(chan fetchResult, len(eps))
+	launched, inflight := 0, 0
+	launch := func() {
+		e := eps[launched]
+		launched++
+		inflight++
+		go func() {
+			ids, err := e.FetchIDs(ctx, n)
+			results <- fetchResult{e: e, ids: ids, err: err}
+		}()
+	}
+	launch()
+
+	var hedge <-chan time.Time
+	if c.opt.hedgeDelay > 0 && len(eps) > 1 {
+		t := time.NewTicker(c.opt.hedgeDelay)
+		defer t.Stop()
+		hedge = t.C
+	}
+	var lastErr error
+	for inflight > 0 {
+		select {
+		case r := <-results:
+			inflight--
+			if r.err == nil {
+				r.e.succeed()
+				return r.ids, nil
+			}
+			lastErr = r.err
+			if ctx.Err() == nil && r.e.fail(c.opt.failThreshold, c.opt.cooldown) {
+				c.log().Warning("endpoint cooling down", "endpoint", fmt.Sprint(r.e.Endpoint), "err", r.err)
+			}
+			if launched < len(eps) && ctx.Err() == nil {
+				launch()
+			}
+		case <-hedge:
+			if launched < len(eps) {
+				launch()
+			}
+		}
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,183 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	snowFlake "github.com/lypee/snowFlake"
+	"github.com/lypee/snowFlake/common"
+	"github.com/lypee/snowFlake/config"
+	"github.com/lypee/snowFlake/server/httpServer"
+)
+
+// fakeEndpoint 按顺序发号, 可以注入错误和延迟
+type fakeEndpoint struct {
+	mu    sync.Mutex
+	next  uint64
+	err   error
+	delay time.Duration
+	calls int32
+}
+
+func newFake(start uint64) *fakeEndpoint {
+	return &fakeEndpoint{next: start}
+}
+
+func (f *fakeEndpoint) FetchIDs(ctx context.Context, n int) ([]uint64, error) {
+	atomic.AddInt32(&f.calls, 1)
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	ids := make([]uint64, n)
+	for i := range ids {
+		ids[i] = f.next
+		f.next++
+	}
+	return ids, nil
+}
+
+func (f *fakeEndpoint) callCount() int {
+	return int(atomic.LoadInt32(&f.calls))
+}
+
+func TestClient_prefetch(t *testing.T) {
+	ep := newFake(1)
+	c, err := New([]Endpoint{ep}, WithPrefetch(100, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	seen := map[uint64]bool{}
+	for i := 0; i < 500; i++ {
+		id, err := c.NextID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[id] {
+			t.Fatalf("duplicate id %d", id)
+		}
+		seen[id] = true
+	}
+	ids, err := c.NextIDs(250)
+	if err != nil || len(ids) != 250 {
+		t.Fatalf("NextIDs: %v %d", err, len(ids))
+	}
+	// 大部分ID应来自预取的批次, 而不是逐个请求
+	if calls := ep.callCount(); calls > 60 {
+		t.Fatalf("too many fetches: %d", calls)
+	}
+
+	c.Close()
+	if _, err = c.NextID(); !errors.Is(err, common.WorkerClosedErr) {
+		t.Fatalf("NextID after Close: %v", err)
+	}
+}
+
+func TestClient_failover(t *testing.T) {
+	bad, good := newFake(1), newFake(1000)
+	bad.err = common.LeaseConflictErr
+	c, err := New([]Endpoint{bad, good}, WithPrefetch(0, 0), WithFailover(2, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 10; i++ {
+		id, err := c.NextID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id < 1000 {
+			t.Fatalf("id %d should come from the healthy endpoint", id)
+		}
+	}
+	// 连续失败两次后进入冷却, 之后不再先请求它
+	if calls := bad.callCount(); calls != 2 {
+		t.Fatalf("failing endpoint called %d times, want 2", calls)
+	}
+
+	good.mu.Lock()
+	good.err = errors.New("down")
+	good.mu.Unlock()
+	if _, err = c.NextID(); err == nil {
+		t.Fatal("NextID should fail when every endpoint fails")
+	}
+}
+
+func TestClient_hedge(t *testing.T) {
+	slow, fast := newFake(1), newFake(1000)
+	slow.delay = time.Second
+	c, err := New([]Endpoint{slow, fast}, WithPrefetch(0, 0), WithHedgeDelay(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	start := time.Now()
+	id, err := c.NextID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id < 1000 || time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("hedged request should win: id %d after %v", id, time.Since(start))
+	}
+	// 被取消的慢请求不计为失败
+	if !c.endpoints[0].healthy(time.Now()) {
+		t.Fatal("cancelled request should not mark the endpoint down")
+	}
+}
+
+func TestHTTPEndpoint(t *testing.T) {
+	sf, err := snowFlake.NewWorker(snowFlake.WithDataCenterId(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sf.Close(context.Background())
+	ts := httptest.NewServer(httpServer.New(sf, &config.Config{}).Handler())
+	defer ts.Close()
+
+	ep := &HTTPEndpoint{BaseURL: ts.URL}
+	ids, err := ep.FetchIDs(context.Background(), httpServer.MaxBatch+5)
+	if err != nil || len(ids) != httpServer.MaxBatch+5 {
+		t.Fatalf("FetchIDs: %v %d", err, len(ids))
+	}
+	if p := sf.Decode(ids[0]); p.DataCenterID != 3 {
+		t.Fatalf("unexpected id %+v", p)
+	}
+
+	down := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		rw.Write([]byte(`{"code":10009,"msg":"lease lost"}`))
+	}))
+	defer down.Close()
+	_, err = (&HTTPEndpoint{BaseURL: down.URL}).FetchIDs(context.Background(), 1)
+	var se *StatusError
+	if !errors.As(err, &se) || se.Status != http.StatusServiceUnavailable || !errors.Is(err, common.LeaseConflictErr) {
+		t.Fatalf("FetchIDs err: %v", err)
+	}
+
+	c, err := New([]Endpoint{&HTTPEndpoint{BaseURL: down.URL}, ep})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err = c.NextIDs(10); err != nil {
+		t.Fatalf("client should fail over to the healthy server: %v", err)
+	}
+}
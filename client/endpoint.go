@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/lypee/snowFlake/common"
+	"github.com/lypee/snowFlake/server/grpcServer"
+	"github.com/lypee/snowFlake/server/httpServer"
+)
+
+// Endpoint 一个发号服务端点
+type Endpoint interface {
+	FetchIDs(ctx context.Context, n int) ([]uint64, error)
+}
+
+// HTTPEndpoint 通过 GET /ids 取号, 超过服务端单次上限时分批请求
+type HTTPEndpoint struct {
+	BaseURL string       // 例如 http://10.0.0.1:8090
+	Client  *http.Client // 为空时使用 http.DefaultClient
+}
+
+func (e *HTTPEndpoint) String() string {
+	return e.BaseURL
+}
+
+func (e *HTTPEndpoint) FetchIDs(ctx context.Context, n int) ([]uint64, error) {
+	ids := make([]uint64, 0, n)
+	for len(ids) < n {
+		count := n - len(ids)
+		if count > httpServer.MaxBatch {
+			count = httpServer.MaxBatch
+		}
+		batch, err := e.fetch(ctx, count)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, batch...)
+	}
+	return ids, nil
+}
+
+func (e *HTTPEndpoint) fetch(ctx context.Context, count int) ([]uint64, error) {
+	u := strings.TrimRight(e.BaseURL, "/") + "/ids?" + url.Values{"count": {strconv.Itoa(count)}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	hc := e.Client
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Code int    `json:"code"`
+			Msg  string `json:"msg"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return nil, &StatusError{Endpoint: e.BaseURL, Status: resp.StatusCode, Code: body.Code, Msg: body.Msg}
+	}
+	var body struct {
+		IDs []string `json:"ids"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if len(body.IDs) != count {
+		return nil, fmt.Errorf("client: %s returned %d ids, want %d", e.BaseURL, len(body.IDs), count)
+	}
+	ids := make([]uint64, len(body.IDs))
+	for i, s := range body.IDs {
+		if ids[i], err = strconv.ParseUint(s, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// StatusError HTTP 端点返回的非200响应, 可以用 errors.Is(err, common.LeaseConflictErr) 按错误码判断
+type StatusError struct {
+	Endpoint string
+	Status   int
+	Code     int
+	Msg      string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %d %s", e.Endpoint, e.Status, e.Msg)
+}
+
+// Unwrap 返回登记的同码错误, 未登记时返回 nil
+func (e *StatusError) Unwrap() error {
+	if ce, ok := common.LookupErr(e.Code); ok {
+		return ce
+	}
+	return nil
+}
+
+// GRPCEndpoint 通过 gRPC NextIDs 取号, 超过服务端单次上限时分批请求; c 由调用方关闭
+func GRPCEndpoint(c *grpcServer.Client) Endpoint {
+	return grpcEndpoint{c}
+}
+
+type grpcEndpoint struct {
+	c *grpcServer.Client
+}
+
+func (e grpcEndpoint) FetchIDs(ctx context.Context, n int) ([]uint64, error) {
+	ids := make([]uint64, 0, n)
+	for len(ids) < n {
+		count := n - len(ids)
+		if count > grpcServer.MaxBatch {
+			count = grpcServer.MaxBatch
+		}
+		batch, err := e.c.NextIDs(ctx, count)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, batch...)
+	}
+	return ids, nil
+}
@@ -0,0 +1,64 @@
+package snowFlake
+
+import (
+	"context"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+// Close 停止发号并释放本worker的租约:
+// 等待进行中的 NextID 返回, 停止后台协程, 上报最终时间戳后释放workerId(写入墓碑)并关闭zk连接
+// ctx 到期时返回 ctx.Err(), 此时不再发号但租约尚未释放, 可以再次调用 Close
+// 信号处理由调用方负责, 例如收到 SIGTERM 后调用 Close
+func (w *SfWorker) Close(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		w.closeMu.Lock()
+		w.closed = true
+		w.closeMu.Unlock()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	w.closeOnce.Do(func() {
+		w.closeErr = w.release()
+	})
+	return w.closeErr
+}
+
+func (w *SfWorker) release() error {
+	close(w.stopCh)
+	if w.unwatch != nil {
+		w.unwatch()
+	}
+	if w.leaseCachePath != "" {
+		w.saveLease(w.leaseCachePath)
+	}
+	if w.ServerType == common.ServerTypeLease {
+		return w.releaseLease()
+	}
+	if w.ServerType != common.ServerTypeZk {
+		return nil
+	}
+
+	w.mu.Lock()
+	owned := !w.degraded && !w.leaseLost
+	w.mu.Unlock()
+	var err error
+	if owned {
+		workerId := int(w.workerID)
+		if stampErr := w.srv.zkSrv.ReportStamp(workerId, w.heartbeatStamp()); stampErr != nil {
+			w.log().WarningF("zkSrv.ReportStamp err:[%+v], workerId:[%d]", stampErr, workerId)
+		}
+		if err = w.srv.zkSrv.ReleaseWorkerId(workerId); err != nil {
+			w.log().WarningF("zkSrv.ReleaseWorkerId err:[%+v], workerId:[%d]", err, workerId)
+		}
+	}
+	w.srv.zkSrv.Shutdown()
+	w.setDegraded(false)
+	return err
+}
@@ -0,0 +1,34 @@
+package snowFlake
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSfWorker_Close(t *testing.T) {
+	sf := newWorker(1, defaultWorkerOpt())
+	if _, err := sf.NextID(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 有进行中的调用时等待, ctx 到期返回
+	sf.closeMu.RLock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := sf.Close(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Close with in-flight call: %v", err)
+	}
+	sf.closeMu.RUnlock()
+
+	if err := sf.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sf.NextID(); !errors.Is(err, ErrWorkerClosed) {
+		t.Fatalf("NextID after Close: %v", err)
+	}
+	if err := sf.Close(context.Background()); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+// snowflake-server 按配置文件启动 HTTP 发号服务(含 Tags 中各业务的 /id/{tag}), 配置了 Grpc.Listen / Resp.Listen / Unix.Path 时同时启动 gRPC / RESP / sidecar 服务;
+// RESP 服务的 bizTag 同样为 Tags 中的业务
+//
+//	snowflake-server -conf conf -file conf.yaml
+package main
+
+import (
+	"context"
+	"flag"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+
+	snowFlake "github.com/lypee/snowFlake"
+	"github.com/lypee/snowFlake/base"
+	"github.com/lypee/snowFlake/config"
+	"github.com/lypee/snowFlake/server/grpcServer"
+	"github.com/lypee/snowFlake/server/httpServer"
+	"github.com/lypee/snowFlake/server/leaseServer"
+	"github.com/lypee/snowFlake/server/respServer"
+	"github.com/lypee/snowFlake/server/unixServer"
+	"github.com/lypee/snowFlake/server/zkServer"
+)
+
+func main() {
+	dir := flag.String("conf", "conf", "config directory")
+	file := flag.String("file", "conf.yaml", "config file name, yaml/json/toml")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "graceful shutdown timeout")
+	flag.Parse()
+
+	err := run(*dir, *file, *shutdownTimeout)
+	if err != nil {
+		base.ErrorF("snowflake-server err:[%+v]", err)
+	}
+	config.CloseLogger()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+func run(dir, file string, shutdownTimeout time.Duration) error {
+	if err := config.InitConfig(dir, file); err != nil {
+		return err
+	}
+	config.Watch()
+
+	sf, err := snowFlake.NewWorker(snowFlake.WithConfig(config.Current()))
+	if err != nil {
+		return err
+	}
+	srv := httpServer.New(sf, config.Current())
+	tags := snowFlake.NewRegistry(sf)
+	if err = tags.RegisterTags(config.Current()); err != nil {
+		sf.Close(context.Background())
+		return err
+	}
+	srv.HandleTags(tags)
+	if config.Current().Lease.Enabled {
+		leases, closeLeases := newLeaseServer(config.Current(), sf.Status())
+		defer closeLeases()
+		srv.Handle("/leases", leases.Handler())
+		srv.Handle("/leases/", leases.Handler())
+	}
+	cancel := config.OnChange(func(_, c *config.Config) {
+		srv.Apply(c)
+		// 新增的业务在运行中生效
+		if err := tags.RegisterTags(c); err != nil {
+			base.WarningF("register tags err:[%+v]", err)
+		}
+	})
+	defer cancel()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	errCh := make(chan error, 4)
+	var grpcSrv *grpc.Server
+	if addr := config.Current().Grpc.Listen; addr != "" {
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			sf.Close(context.Background())
+			return err
+		}
+		grpcSrv = grpc.NewServer()
+		grpcServer.New(sf).Register(grpcSrv)
+		base.InfoF("grpc server listen on %s", addr)
+		go func() {
+			errCh <- grpcSrv.Serve(lis)
+		}()
+	}
+	var respSrv *respServer.Server
+	if addr := config.Current().Resp.Listen; addr != "" {
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			if grpcSrv != nil {
+				grpcSrv.Stop()
+			}
+			sf.Close(context.Background())
+			return err
+		}
+		respSrv = respServer.New(sf)
+		respSrv.HandleTags(tags)
+		base.InfoF("resp server listen on %s", addr)
+		go func() {
+			errCh <- respSrv.Serve(lis)
+		}()
+	}
+	var unixSrv *unixServer.Server
+	if path := config.Current().Unix.Path; path != "" {
+		lis, err := unixServer.Listen(path)
+		if err != nil {
+			if grpcSrv != nil {
+				grpcSrv.Stop()
+			}
+			if respSrv != nil {
+				respSrv.Shutdown(context.Background())
+			}
+			sf.Close(context.Background())
+			return err
+		}
+		unixSrv = unixServer.New(sf)
+		base.InfoF("unix server listen on %s", path)
+		go func() {
+			errCh <- unixSrv.Serve(lis)
+		}()
+	}
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		base.InfoF("shutting down")
+	}
+
+	// 先停止接收请求, 再释放 workerId
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if shutdownErr := srv.Shutdown(shutdownCtx); shutdownErr != nil {
+		base.WarningF("http shutdown err:[%+v]", shutdownErr)
+	}
+	if grpcSrv != nil {
+		stopGrpc(shutdownCtx, grpcSrv)
+	}
+	if respSrv != nil {
+		if shutdownErr := respSrv.Shutdown(shutdownCtx); shutdownErr != nil {
+			base.WarningF("resp shutdown err:[%+v]", shutdownErr)
+		}
+	}
+	if unixSrv != nil {
+		if shutdownErr := unixSrv.Shutdown(shutdownCtx); shutdownErr != nil {
+			base.WarningF("unix shutdown err:[%+v]", shutdownErr)
+		}
+	}
+	if closeErr := sf.Close(shutdownCtx); closeErr != nil {
+		base.WarningF("worker close err:[%+v]", closeErr)
+	}
+	return err
+}
+
+// newLeaseServer 租约服务与本服务的worker属于同一数据中心; 使用zk时另开一个会话分配,
+// 否则在进程内分配并避开本worker的ID, 配置校验保证此时已开启 Lease.UnsafeMemBackend
+// 返回的 close 在 HTTP 服务停止后调用, 释放未到期的租约
+func newLeaseServer(c *config.Config, st snowFlake.WorkerStatus) (*leaseServer.Server, func()) {
+	if c.Center.Name != "zk" {
+		base.WarningF("lease server uses the in-process allocator, leases are lost on restart")
+		s := leaseServer.New(leaseServer.NewMemBackend(int(st.WorkerID)), st.DataCenterID, c.Lease.TTL)
+		return s, s.Close
+	}
+	opt := zkServer.DefaultOpt()
+	for _, of := range snowFlake.ZkConnOpts(c) {
+		of(opt)
+	}
+	zkServer.WithDataCenterId(st.DataCenterID)(opt)
+	zkSrv := zkServer.NewZkServer(make(chan error, 3), opt)
+	s := leaseServer.New(leaseServer.ZkBackend(zkSrv), st.DataCenterID, c.Lease.TTL)
+	return s, func() {
+		s.Close()
+		zkSrv.Shutdown()
+	}
+}
+
+// stopGrpc 等待进行中的调用完成, ctx 到期后强制关闭
+func stopGrpc(ctx context.Context, s *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.Stop()
+	}
+}
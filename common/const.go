@@ -1,19 +1,21 @@
 package common
 
 const (
-	WorkerIDBits     = uint64(12) // 10 bit 工作机器ID中的 5bit workerID
-	DataCenterIDBits = uint64(3)  // 10 bit 工作机器ID中的 5bit dataCenterID
-	SequenceBits     = uint64(13)
+	WorkerIDBits     = uint64(12) // 工作机器ID
+	DataCenterIDBits = uint64(3)  // 数据中心ID
+	SequenceBits     = uint64(10) // 1毫秒内最多生成1024个ID; 旧版本为13位且各段重叠, 布局不兼容, 见 README 升级说明
 
 	MaxWorkerID     = int64(-1) ^ (int64(-1) << WorkerIDBits) //节点ID的最大值 用于防止溢出
 	MaxDataCenterID = int64(-1) ^ (int64(-1) << DataCenterIDBits)
 	MaxSequence     = int64(-1) ^ (int64(-1) << SequenceBits)
 
-	TimeLeft = uint8(25) // timeLeft = workerIDBits + sequenceBits // 时间戳向左偏移量
-	DataLeft = uint8(16) // dataLeft = dataCenterIDBits + sequenceBits
-	WorkLeft = uint8(12) // workLeft = sequenceBits // 节点IDx向左偏移量
+	// 各段互不重叠: | 时间戳 | 数据中心ID | 工作机器ID | 序列号 |
+	TimeLeft = uint8(SequenceBits + WorkerIDBits + DataCenterIDBits) // 时间戳向左偏移量 25
+	DataLeft = uint8(SequenceBits + WorkerIDBits)                    // 数据中心ID向左偏移量 22
+	WorkLeft = uint8(SequenceBits)                                   // 节点ID向左偏移量 10
 	// 2020-05-20 08:0:00 +0800 CST
-	Twepoch = int64(15809923200000) // 常量时间戳(毫秒) 13
+	// 旧版本误写为 15809923200000(2470年), 生成的ID与当前的ID空间重叠, 见 README 升级说明
+	Twepoch = int64(1589932800000) // 常量时间戳(毫秒) 13
 )
 
 const (
@@ -30,4 +32,5 @@ type ServerType int
 const (
 	ServerTypeDefault = iota
 	ServerTypeZk
+	ServerTypeLease // workerId 由外部租约服务分配
 )
@@ -1,36 +1,102 @@
 package common
 
-import "encoding/json"
+import (
+	"fmt"
+	"sync"
+	"time"
+)
 
+// Err 带错误码的错误, 是不可变的值; WithTrueErr 返回附带原因的副本, 不会修改包级别的错误值
+// 可以用 errors.Is(err, common.OpErr) 按错误码判断, 用 errors.Unwrap 取得原因
 type Err struct {
 	Code    int
 	Msg     string
 	TrueErr error
 }
 
+var (
+	registryMu sync.RWMutex
+	registry   = map[int]Err{}
+)
+
+// NewErr 登记错误码, 错误码重复时 panic
 func NewErr(code int, msg string) Err {
-	return Err{
-		Code: code,
-		Msg:  msg,
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if old, ok := registry[code]; ok {
+		panic(fmt.Sprintf("duplicate error code %d: %s and %s", code, old.Msg, msg))
 	}
+	e := Err{Code: code, Msg: msg}
+	registry[code] = e
+	return e
+}
+
+// LookupErr 按错误码查找登记的错误
+func LookupErr(code int) (Err, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	e, ok := registry[code]
+	return e, ok
 }
 
 func (e Err) Error() string {
-	err, _ := json.Marshal(e)
-	return string(err)
+	if e.TrueErr == nil {
+		return fmt.Sprintf("%d %s", e.Code, e.Msg)
+	}
+	return fmt.Sprintf("%d %s: %v", e.Code, e.Msg, e.TrueErr)
 }
 
-func (e *Err) WithTrueErr(err error) error {
+// WithTrueErr 返回以 err 为原因的副本
+func (e Err) WithTrueErr(err error) error {
 	e.TrueErr = err
 	return e
 }
 
+func (e Err) Unwrap() error {
+	return e.TrueErr
+}
+
+// Is 错误码相同即视为同一错误, 与是否附带原因无关
+func (e Err) Is(target error) bool {
+	t, ok := target.(Err)
+	return ok && t.Code == e.Code
+}
+
 var (
-	OpErr          Err = Err{Code: 10000, Msg: "OpErr"}
-	ConnErr        Err = Err{Code: 10001, Msg: "ConnErr"}
-	StartConnErr   Err = Err{Code: 10002, Msg: "StartConnErr"}
-	ServersErr     Err = Err{Code: 10003, Msg: "ServersErr"}
-	InvalidPathErr Err = Err{Code: 10003, Msg: "InvalidPathErr"}
-	NodeNameErr    Err = Err{Code: 10004, Msg: "NodeNameErr"}
-	PathLengthErr  Err = Err{Code: 10005, Msg: "PathLengthErr"}
+	OpErr          = NewErr(10000, "OpErr")
+	ConnErr        = NewErr(10001, "ConnErr")
+	StartConnErr   = NewErr(10002, "StartConnErr")
+	ServersErr     = NewErr(10003, "ServersErr")
+	NodeNameErr    = NewErr(10004, "NodeNameErr")
+	PathLengthErr  = NewErr(10005, "PathLengthErr")
+	ClockSkewErr   = NewErr(10006, "ClockSkewErr")
+	InvalidPathErr = NewErr(10011, "InvalidPathErr")
+
+	DataCenterExhaustedErr = NewErr(10007, "DataCenterExhaustedErr")
+	InvalidDataCenterErr   = NewErr(10008, "InvalidDataCenterErr")
+	LeaseConflictErr       = NewErr(10009, "LeaseConflictErr")
+	WorkerClosedErr        = NewErr(10010, "WorkerClosedErr")
+
+	ClockBackwardsErr    = NewErr(10012, "ClockBackwardsErr")
+	SequenceExhaustedErr = NewErr(10013, "SequenceExhaustedErr")
+	WorkerIdExhaustedErr = NewErr(10014, "WorkerIdExhaustedErr")
+	InvalidLayoutErr     = NewErr(10015, "InvalidLayoutErr")
+	InvalidConfigErr     = NewErr(10016, "InvalidConfigErr")
+	IdentityChangedErr   = NewErr(10017, "IdentityChangedErr")
+
+	UnknownBizTagErr   = NewErr(10018, "UnknownBizTagErr")
+	SegmentNotReadyErr = NewErr(10019, "SegmentNotReadyErr")
 )
+
+// ClockBackwardsError 时钟回拨, Drift 为当前时间落后于上次发号时间戳的时长
+type ClockBackwardsError struct {
+	Drift time.Duration
+}
+
+func (e *ClockBackwardsError) Error() string {
+	return fmt.Sprintf("%d %s: clock moved backwards by %v", ClockBackwardsErr.Code, ClockBackwardsErr.Msg, e.Drift)
+}
+
+func (e *ClockBackwardsError) Unwrap() error {
+	return ClockBackwardsErr
+}
@@ -0,0 +1,69 @@
+package common
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestErr_WithTrueErr(t *testing.T) {
+	cause := errors.New("connection refused")
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = OpErr.WithTrueErr(cause)
+		}(i)
+	}
+	wg.Wait()
+	if OpErr.TrueErr != nil {
+		t.Fatal("WithTrueErr must not modify the package-level value")
+	}
+	for _, err := range errs {
+		if !errors.Is(err, OpErr) || errors.Is(err, ConnErr) {
+			t.Fatalf("errors.Is mismatch: %v", err)
+		}
+		if errors.Unwrap(err) != cause {
+			t.Fatalf("unwrap: %v", errors.Unwrap(err))
+		}
+	}
+}
+
+func TestNewErr_duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic on duplicate code")
+		}
+	}()
+	NewErr(OpErr.Code, "dup")
+}
+
+func TestClockBackwardsError(t *testing.T) {
+	var err error = &ClockBackwardsError{Drift: 5 * time.Millisecond}
+	if !errors.Is(err, ClockBackwardsErr) {
+		t.Fatal("expected ClockBackwardsErr")
+	}
+	var cb *ClockBackwardsError
+	if !errors.As(err, &cb) || cb.Drift != 5*time.Millisecond {
+		t.Fatalf("drift: %v", cb)
+	}
+}
+
+func TestValidateLayout(t *testing.T) {
+	now := time.Now().UnixNano() / 1e6
+	if err := ValidateLayout(WorkerIDBits, DataCenterIDBits, SequenceBits, Twepoch, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateLayout(WorkerIDBits, DataCenterIDBits, SequenceBits, now+1, now); !errors.Is(err, InvalidLayoutErr) {
+		t.Fatalf("future epoch: %v", err)
+	}
+	if err := ValidateLayout(40, 20, 10, Twepoch, now); !errors.Is(err, InvalidLayoutErr) {
+		t.Fatalf("too many bits: %v", err)
+	}
+	if err := ValidateLayout(20, 10, 20, 0, now); !errors.Is(err, InvalidLayoutErr) {
+		t.Fatalf("timestamp overflow: %v", err)
+	}
+}
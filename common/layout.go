@@ -0,0 +1,19 @@
+package common
+
+import "fmt"
+
+// ValidateLayout 校验ID的位布局: 各段位数之和不超过63位, 起始时间 epoch 不晚于 now,
+// 且 now 距 epoch 的毫秒数能放进剩余的时间戳位中(ID以 uint64 返回, 时间戳可使用最高位)
+func ValidateLayout(workerBits, dataCenterBits, sequenceBits uint64, epoch, now int64) error {
+	shift := workerBits + dataCenterBits + sequenceBits
+	if shift > 63 {
+		return InvalidLayoutErr.WithTrueErr(fmt.Errorf("%d bits left no room for timestamp", shift))
+	}
+	if epoch > now {
+		return InvalidLayoutErr.WithTrueErr(fmt.Errorf("epoch %d is after now %d", epoch, now))
+	}
+	if elapsed := uint64(now - epoch); shift > 0 && elapsed >= uint64(1)<<(64-shift) {
+		return InvalidLayoutErr.WithTrueErr(fmt.Errorf("%d ms since epoch overflow %d timestamp bits", elapsed, 64-shift))
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package snowFlake
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lypee/snowFlake/common"
+	"github.com/lypee/snowFlake/config"
+	"github.com/lypee/snowFlake/server/zkServer"
+)
+
+// WithConfig 按配置文件设置worker参数, 之后的选项可以覆盖其中的设置
+// worker 会订阅 config.Reload, 运行中修改的时钟回拨容忍度随之生效
+func WithConfig(c *config.Config) WorkerOptFunc {
+	return func(opt *workerOpt) {
+		opt.watchConfig = true
+		opt.center = c.Center.Name
+		opt.epoch = c.Generator.Epoch
+		opt.maxClockSkew = c.Generator.MaxClockSkew
+		opt.maxRollback = c.Generator.MaxRollback
+		if c.App.DataCenterId >= 0 {
+			opt.dataCenterID = c.App.DataCenterId
+		}
+		opt.dataCenterName = c.App.DataCenter
+		if c.Generator.LeaseCache != "" {
+			WithLeaseCache(c.Generator.LeaseCache, c.Generator.LeaseMaxStale)(opt)
+		}
+		if c.Generator.HwmPath != "" {
+			WithHighWaterMark(c.Generator.HwmPath, c.Generator.HwmReserve)(opt)
+		}
+
+		opt.connOfs = append(opt.connOfs, ZkConnOpts(c)...)
+	}
+}
+
+// ZkConnOpts 配置文件中的zk连接参数
+func ZkConnOpts(c *config.Config) []zkServer.ConnOptFunc {
+	zk := c.Zookeeper
+	ofs := []zkServer.ConnOptFunc{
+		zkServer.WithServers(zk.Servers),
+		zkServer.WithAppName(c.App.Name),
+		zkServer.WithCoolDown(zk.CoolDown),
+		zkServer.WithProbeCount(zk.ProbeCount),
+		zkServer.WithAuditRetention(zk.Audit.MaxAge, zk.Audit.MaxRecords),
+		zkServer.WithDigestAuth(zk.Digest.User, zk.Digest.Password),
+	}
+	if zk.SessionTimeout > 0 {
+		ofs = append(ofs, zkServer.WithSessionTimeout(zk.SessionTimeout))
+	}
+	if zk.HeartbeatInterval > 0 {
+		ofs = append(ofs, zkServer.WithHeartbeatInterval(zk.HeartbeatInterval))
+	}
+	return ofs
+}
+
+// ApplyConfig 应用运行中可以修改的配置; 起始时间或数据中心与本worker不一致时返回 IdentityChangedErr
+func (w *SfWorker) ApplyConfig(c *config.Config) error {
+	if c.Generator.Epoch != w.epoch {
+		return common.IdentityChangedErr.WithTrueErr(fmt.Errorf("epoch %d differs from running %d", c.Generator.Epoch, w.epoch))
+	}
+	if c.App.DataCenterId >= 0 && c.App.DataCenterId != w.dataCenterID {
+		return common.IdentityChangedErr.WithTrueErr(fmt.Errorf("dataCenterId %d differs from running %d", c.App.DataCenterId, w.dataCenterID))
+	}
+	w.SetMaxRollback(c.Generator.MaxRollback)
+	return nil
+}
+
+// SetMaxRollback 修改可容忍的时钟回拨
+func (w *SfWorker) SetMaxRollback(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.maxRollback = d
+}
+
+func (w *SfWorker) watchConfig() {
+	w.unwatch = config.OnChange(func(_, c *config.Config) {
+		if err := w.ApplyConfig(c); err != nil {
+			w.log().Error("apply config fail", "err", err)
+		}
+	})
+}
+
+// RegisterTags 登记配置中的业务, 布局字段为0时取 Generator 的布局; 已登记且布局相同的业务不受影响
+func (r *Registry) RegisterTags(c *config.Config) error {
+	for _, t := range c.Tags {
+		epoch := t.Epoch
+		if epoch == 0 {
+			epoch = c.Generator.Epoch
+		}
+		l := Layout{
+			Epoch:            epoch,
+			WorkerIDBits:     t.WorkerIDBits,
+			DataCenterIDBits: t.DataCenterIDBits,
+			SequenceBits:     t.SequenceBits,
+			ReservedBits:     t.ReservedBits,
+		}
+		if _, err := r.Register(t.Name, l); err != nil {
+			return fmt.Errorf("tag %s: %w", t.Name, err)
+		}
+	}
+	return nil
+}
@@ -1,29 +1,448 @@
 package config
 
 import (
-	"log"
-	"os"
+	"fmt"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
+
+	"github.com/lypee/snowFlake/common"
 )
 
-func InitConfig(path, conf string) error {
-	filename := path + conf
-	_, e := os.Stat(filename)
-	if e != nil {
-		log.Fatalf("file:%s, error:%v\n", filename, e.Error())
-		return e
+// EnvPrefix 环境变量前缀, 如 SNOWFLAKE_ZOOKEEPER_SERVERS 覆盖 Zookeeper.Servers
+const EnvPrefix = "SNOWFLAKE"
+
+// Config 配置文件的结构, 支持 yaml/json/toml
+type Config struct {
+	App       AppConfig       `mapstructure:"App"`
+	Center    CenterConfig    `mapstructure:"Center"`
+	Generator GeneratorConfig `mapstructure:"Generator"`
+	Zookeeper ZookeeperConfig `mapstructure:"Zookeeper"`
+	Log       LogConfig       `mapstructure:"Log"`
+	Http      HttpConfig      `mapstructure:"Http"`
+	Grpc      GrpcConfig      `mapstructure:"Grpc"`
+	Resp      RespConfig      `mapstructure:"Resp"`
+	Unix      UnixConfig      `mapstructure:"Unix"`
+	Lease     LeaseConfig     `mapstructure:"Lease"`
+	Tags      []TagConfig     `mapstructure:"Tags"`
+	Metrics   MetricsConfig   `mapstructure:"Metrics"`
+}
+
+type AppConfig struct {
+	Name         string `mapstructure:"Name"`
+	DataCenter   string `mapstructure:"DataCenter"`   // 数据中心名称, 由分配器分配ID
+	DataCenterId int64  `mapstructure:"DataCenterId"` // 小于0表示未指定
+}
+
+// CenterConfig workerId 分配器, Name 为 zk 时使用 Zookeeper, 为空时固定为1
+type CenterConfig struct {
+	Name string `mapstructure:"Name"`
+}
+
+type GeneratorConfig struct {
+	Epoch         int64         `mapstructure:"Epoch"`         // 起始时间戳(毫秒)
+	MaxClockSkew  time.Duration `mapstructure:"MaxClockSkew"`  // 与其他存活worker平均时间的最大允许偏差
+	MaxRollback   time.Duration `mapstructure:"MaxRollback"`   // 可容忍的时钟回拨, 不超过该值时等待时钟追上
+	LeaseCache    string        `mapstructure:"LeaseCache"`    // 本地租约缓存文件
+	LeaseMaxStale time.Duration `mapstructure:"LeaseMaxStale"` // 缓存的租约最长可用多久, 不能超过 Zookeeper.CoolDown - Zookeeper.SessionTimeout
+	HwmPath       string        `mapstructure:"HwmPath"`       // 时间戳上界文件
+	HwmReserve    time.Duration `mapstructure:"HwmReserve"`
+}
+
+type ZookeeperConfig struct {
+	Servers           []string      `mapstructure:"Servers"`
+	SessionTimeout    time.Duration `mapstructure:"SessionTimeout"`
+	HeartbeatInterval time.Duration `mapstructure:"HeartbeatInterval"`
+	CoolDown          time.Duration `mapstructure:"CoolDown"`
+	ProbeCount        int           `mapstructure:"ProbeCount"`
+	Audit             AuditConfig   `mapstructure:"Audit"`
+	Digest            DigestConfig  `mapstructure:"Digest"`
+}
+
+type AuditConfig struct {
+	MaxAge     time.Duration `mapstructure:"MaxAge"`
+	MaxRecords int           `mapstructure:"MaxRecords"`
+}
+
+type DigestConfig struct {
+	User     string `mapstructure:"User"`
+	Password string `mapstructure:"Password"`
+}
+
+type LogConfig struct {
+	Path           string        `mapstructure:"Path"`
+	Level          string        `mapstructure:"Level"`
+	Format         string        `mapstructure:"Format"`
+	MaxSize        int64         `mapstructure:"MaxSize"` // MB
+	RotateInterval time.Duration `mapstructure:"RotateInterval"`
+	MaxBackups     int           `mapstructure:"MaxBackups"`
+	Compress       bool          `mapstructure:"Compress"`
+}
+
+type HttpConfig struct {
+	Listen       string        `mapstructure:"Listen"`
+	ReadTimeout  time.Duration `mapstructure:"ReadTimeout"`
+	WriteTimeout time.Duration `mapstructure:"WriteTimeout"`
+	IdleTimeout  time.Duration `mapstructure:"IdleTimeout"`
+	RateLimit    float64       `mapstructure:"RateLimit"` // 每秒请求数, 0表示不限
+	RateBurst    int           `mapstructure:"RateBurst"`
+}
+
+// GrpcConfig gRPC 发号服务, Listen 为空时不启动
+type GrpcConfig struct {
+	Listen string `mapstructure:"Listen"`
+}
+
+// RespConfig Redis 协议(RESP)发号服务, Listen 为空时不启动
+type RespConfig struct {
+	Listen string `mapstructure:"Listen"`
+}
+
+// UnixConfig Unix domain socket 上的 sidecar 发号服务, 同一主机的进程共用一个 workerId, Path 为空时不启动
+type UnixConfig struct {
+	Path string `mapstructure:"Path"`
+}
+
+// LeaseConfig workerId 租约服务, 开启后在 HTTP 端口上提供 /leases
+type LeaseConfig struct {
+	Enabled bool          `mapstructure:"Enabled"`
+	TTL     time.Duration `mapstructure:"TTL"` // 使用zk时不能超过 Zookeeper.CoolDown - Zookeeper.SessionTimeout
+	// UnsafeMemBackend 不使用zk时在进程内分配, 重启后租约丢失, 同一workerId可能同时分配给两个持有者, 仅用于测试
+	UnsafeMemBackend bool `mapstructure:"UnsafeMemBackend"`
+}
+
+// TagConfig 按业务标识发号, 通过 HTTP 的 /id/{Name} 获取; 各业务共用本服务的 workerId 和时钟,
+// 布局字段为0时与 Generator 相同, ReservedBits 为留给业务自行填充的最低位
+type TagConfig struct {
+	Name             string `mapstructure:"Name"`
+	Epoch            int64  `mapstructure:"Epoch"`
+	WorkerIDBits     uint64 `mapstructure:"WorkerIDBits"`
+	DataCenterIDBits uint64 `mapstructure:"DataCenterIDBits"`
+	SequenceBits     uint64 `mapstructure:"SequenceBits"`
+	ReservedBits     uint64 `mapstructure:"ReservedBits"`
+}
+
+type MetricsConfig struct {
+	Enabled bool   `mapstructure:"Enabled"`
+	Path    string `mapstructure:"Path"`
+}
+
+// defaults 默认值, 同时让 viper 知道所有的key, 文件中没有的key也能被环境变量覆盖
+var defaults = map[string]interface{}{
+	"App.Name":                    "",
+	"App.DataCenter":              "",
+	"App.DataCenterId":            -1,
+	"Center.Name":                 "",
+	"Generator.Epoch":             common.Twepoch,
+	"Generator.MaxClockSkew":      "5s",
+	"Generator.MaxRollback":       "0s",
+	"Generator.LeaseCache":        "",
+	"Generator.LeaseMaxStale":     "0s",
+	"Generator.HwmPath":           "",
+	"Generator.HwmReserve":        "3s",
+	"Zookeeper.Servers":           []string{},
+	"Zookeeper.SessionTimeout":    "3s",
+	"Zookeeper.HeartbeatInterval": "3s",
+	"Zookeeper.CoolDown":          "10s",
+	"Zookeeper.ProbeCount":        8,
+	"Zookeeper.Audit.MaxAge":      "720h",
+	"Zookeeper.Audit.MaxRecords":  0,
+	"Zookeeper.Digest.User":       "",
+	"Zookeeper.Digest.Password":   "",
+	"Log.Path":                    "",
+	"Log.Level":                   "INFO",
+	"Log.Format":                  "text",
+	"Log.MaxSize":                 100,
+	"Log.RotateInterval":          "0s",
+	"Log.MaxBackups":              0,
+	"Log.Compress":                false,
+	"Http.Listen":                 "0.0.0.0:8090",
+	"Http.ReadTimeout":            "15s",
+	"Http.WriteTimeout":           "15s",
+	"Http.IdleTimeout":            "60s",
+	"Http.RateLimit":              0,
+	"Http.RateBurst":              0,
+	"Grpc.Listen":                 "",
+	"Resp.Listen":                 "",
+	"Unix.Path":                   "",
+	"Lease.Enabled":               false,
+	"Lease.TTL":                   "5s",
+	"Lease.UnsafeMemBackend":      false,
+	"Tags":                        []map[string]interface{}{},
+	"Metrics.Enabled":             true,
+	"Metrics.Path":                "/debug/vars",
+}
+
+var current atomic.Value
+
+// Current 最近一次成功加载的配置, 未加载时为 nil
+func Current() *Config {
+	c, _ := current.Load().(*Config)
+	return c
+}
+
+func newViper() *viper.Viper {
+	v := viper.New()
+	for k, val := range defaults {
+		v.SetDefault(k, val)
 	}
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	return v
+}
+
+// Load 读取配置文件 filename, 格式由扩展名决定(.yaml/.yml/.json/.toml), 再用 SNOWFLAKE_* 环境变量覆盖
+// 校验失败时返回 *ValidationError, 包含全部的错误项
+func Load(filename string) (*Config, error) {
+	_, c, err := load(filename)
+	return c, err
+}
+
+func load(filename string) (*viper.Viper, *Config, error) {
+	v := newViper()
+	v.SetConfigFile(filename)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, nil, common.InvalidConfigErr.WithTrueErr(fmt.Errorf("read config file %s: %w", filename, err))
+	}
+	c, err := decode(v)
+	return v, c, err
+}
 
-	viper.AutomaticEnv()
+func decode(v *viper.Viper) (*Config, error) {
+	c := &Config{}
+	hook := viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		legacySecondsHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	))
+	if err := v.Unmarshal(c, hook); err != nil {
+		return nil, common.InvalidConfigErr.WithTrueErr(err)
+	}
+	c.Zookeeper.Servers = splitServers(c.Zookeeper.Servers)
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
 
-	viper.SetConfigType("yaml")
-	viper.SetConfigFile(filename)
+// legacySecondsHook 兼容旧配置中不带单位的时长(如 ReadTimeout: 15), 按秒解析; 新配置应写成 "15s"
+func legacySecondsHook(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	if t != reflect.TypeOf(time.Duration(0)) {
+		return data, nil
+	}
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return time.Duration(reflect.ValueOf(data).Int()) * time.Second, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return time.Duration(reflect.ValueOf(data).Uint()) * time.Second, nil
+	case reflect.Float32, reflect.Float64:
+		return time.Duration(reflect.ValueOf(data).Float() * float64(time.Second)), nil
+	case reflect.String:
+		// 环境变量中的 "15"
+		if n, err := strconv.ParseInt(strings.TrimSpace(data.(string)), 10, 64); err == nil {
+			return time.Duration(n) * time.Second, nil
+		}
+	}
+	return data, nil
+}
 
-	e = viper.ReadInConfig()
-	if e != nil {
-		log.Fatalf("read config file %s fail, error: %v\n", filename, e)
-		return e
+// splitServers 兼容 "host1;host2" 形式的地址, 去掉空地址
+func splitServers(servers []string) []string {
+	res := make([]string, 0, len(servers))
+	for _, s := range servers {
+		for _, host := range strings.Split(s, ";") {
+			if host = strings.TrimSpace(host); host != "" {
+				res = append(res, host)
+			}
+		}
+	}
+	return res
+}
+
+// ValidationError 配置校验失败的全部错误项
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d %s: %s", common.InvalidConfigErr.Code, common.InvalidConfigErr.Msg, strings.Join(e.Problems, "; "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return common.InvalidConfigErr
+}
+
+// Validate 校验配置, 返回的 *ValidationError 包含所有不合法的项, 而不是只报告第一个
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+	nonNegative := func(key string, d time.Duration) {
+		if d < 0 {
+			add("%s must not be negative, got %v", key, d)
+		}
+	}
+
+	if c.App.DataCenterId < -1 || c.App.DataCenterId > common.MaxDataCenterID {
+		add("App.DataCenterId must be in [0, %d], got %d", common.MaxDataCenterID, c.App.DataCenterId)
+	}
+	switch c.Center.Name {
+	case "":
+	case "zk":
+		if len(c.Zookeeper.Servers) == 0 {
+			add("Zookeeper.Servers is required when Center.Name is zk")
+		}
+	default:
+		add("Center.Name must be empty or zk, got %q", c.Center.Name)
+	}
+	if c.App.DataCenter != "" && c.Center.Name != "zk" {
+		add("App.DataCenter requires Center.Name zk to allocate a dataCenterId")
+	}
+
+	if err := common.ValidateLayout(common.WorkerIDBits, common.DataCenterIDBits, common.SequenceBits,
+		c.Generator.Epoch, time.Now().UnixNano()/1e6); err != nil {
+		add("Generator.Epoch: %v", err)
+	}
+	nonNegative("Generator.MaxClockSkew", c.Generator.MaxClockSkew)
+	nonNegative("Generator.MaxRollback", c.Generator.MaxRollback)
+	nonNegative("Generator.LeaseMaxStale", c.Generator.LeaseMaxStale)
+	nonNegative("Generator.HwmReserve", c.Generator.HwmReserve)
+	// 缓存的租约只能在zk的安全窗口内使用: 临时节点最迟在会话超时后消失, 再过冷却期其他主机就能占用同一workerId
+	if c.Generator.LeaseCache != "" && c.Center.Name == "zk" {
+		if safe := c.Zookeeper.CoolDown - c.Zookeeper.SessionTimeout; safe <= 0 {
+			add("Generator.LeaseCache requires Zookeeper.CoolDown (%v) to be longer than Zookeeper.SessionTimeout (%v)",
+				c.Zookeeper.CoolDown, c.Zookeeper.SessionTimeout)
+		} else if c.Generator.LeaseMaxStale > safe {
+			add("Generator.LeaseMaxStale must not exceed Zookeeper.CoolDown - Zookeeper.SessionTimeout (%v), got %v",
+				safe, c.Generator.LeaseMaxStale)
+		}
+	}
+	if c.Generator.HwmPath != "" && c.Generator.HwmReserve <= 0 {
+		add("Generator.HwmReserve must be positive when Generator.HwmPath is set")
+	}
+
+	nonNegative("Zookeeper.SessionTimeout", c.Zookeeper.SessionTimeout)
+	nonNegative("Zookeeper.HeartbeatInterval", c.Zookeeper.HeartbeatInterval)
+	nonNegative("Zookeeper.CoolDown", c.Zookeeper.CoolDown)
+	nonNegative("Zookeeper.Audit.MaxAge", c.Zookeeper.Audit.MaxAge)
+	if c.Zookeeper.ProbeCount < 0 {
+		add("Zookeeper.ProbeCount must not be negative, got %d", c.Zookeeper.ProbeCount)
+	}
+	if (c.Zookeeper.Digest.User == "") != (c.Zookeeper.Digest.Password == "") {
+		add("Zookeeper.Digest.User and Zookeeper.Digest.Password must be set together")
+	}
+
+	if _, ok := levelNames[strings.ToUpper(c.Log.Level)]; !ok {
+		add("Log.Level must be one of DEBUG/INFO/WARNING/ERROR, got %q", c.Log.Level)
+	}
+	if f := strings.ToLower(c.Log.Format); f != "text" && f != "json" {
+		add("Log.Format must be text or json, got %q", c.Log.Format)
+	}
+	if c.Log.MaxSize < 0 || c.Log.MaxBackups < 0 {
+		add("Log.MaxSize and Log.MaxBackups must not be negative")
+	}
+	nonNegative("Log.RotateInterval", c.Log.RotateInterval)
+
+	if c.Http.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Http.Listen); err != nil {
+			add("Http.Listen: %v", err)
+		}
+	}
+	if c.Grpc.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Grpc.Listen); err != nil {
+			add("Grpc.Listen: %v", err)
+		}
+	}
+	if c.Resp.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Resp.Listen); err != nil {
+			add("Resp.Listen: %v", err)
+		}
+	}
+	nonNegative("Http.ReadTimeout", c.Http.ReadTimeout)
+	nonNegative("Http.WriteTimeout", c.Http.WriteTimeout)
+	nonNegative("Http.IdleTimeout", c.Http.IdleTimeout)
+	if c.Http.RateLimit < 0 || c.Http.RateBurst < 0 {
+		add("Http.RateLimit and Http.RateBurst must not be negative")
+	}
+
+	if c.Lease.Enabled && c.Lease.TTL <= 0 {
+		add("Lease.TTL must be positive, got %v", c.Lease.TTL)
+	}
+	// 租约服务崩溃后其会话下的临时节点在会话超时后消失, 租约必须在冷却期结束前到期, 否则同一workerId可能再次分配出去
+	if c.Lease.Enabled && c.Center.Name == "zk" {
+		if safe := c.Zookeeper.CoolDown - c.Zookeeper.SessionTimeout; c.Lease.TTL > safe {
+			add("Lease.TTL must not exceed Zookeeper.CoolDown - Zookeeper.SessionTimeout (%v), got %v", safe, c.Lease.TTL)
+		}
+	} else if c.Lease.Enabled && !c.Lease.UnsafeMemBackend {
+		add("Lease.Enabled requires Center.Name zk; set Lease.UnsafeMemBackend to use the in-process allocator, which loses leases on restart (tests only)")
+	}
+
+	tags := make(map[string]bool, len(c.Tags))
+	for i, t := range c.Tags {
+		if t.Name == "" || strings.Contains(t.Name, "/") {
+			add("Tags[%d].Name must be non-empty and must not contain /, got %q", i, t.Name)
+			continue
+		}
+		if tags[t.Name] {
+			add("Tags[%d].Name %q is duplicated", i, t.Name)
+		}
+		tags[t.Name] = true
+		l := t.withDefaults(c.Generator.Epoch)
+		if err := common.ValidateLayout(l.WorkerIDBits, l.DataCenterIDBits, l.SequenceBits+l.ReservedBits,
+			l.Epoch, time.Now().UnixNano()/1e6); err != nil {
+			add("Tags[%s]: %v", t.Name, err)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
 	}
 	return nil
 }
+
+// withDefaults 布局字段为0时取 Generator 的布局, epoch 为 Generator.Epoch
+func (t TagConfig) withDefaults(epoch int64) TagConfig {
+	if t.Epoch == 0 {
+		t.Epoch = epoch
+	}
+	if t.WorkerIDBits == 0 {
+		t.WorkerIDBits = common.WorkerIDBits
+	}
+	if t.DataCenterIDBits == 0 {
+		t.DataCenterIDBits = common.DataCenterIDBits
+	}
+	if t.SequenceBits == 0 {
+		t.SequenceBits = common.SequenceBits
+	}
+	return t
+}
+
+var levelNames = map[string]bool{"DEBUG": true, "INFO": true, "WARN": true, "WARNING": true, "ERROR": true}
+
+// InitConfig 加载 path 目录下的配置文件 conf, 并同步到全局 viper 供尚未迁移到 Config 的代码使用
+// 文件不存在或校验失败时返回错误, 不会退出进程
+func InitConfig(path, conf string) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	filename := filepath.Join(path, conf)
+	v, c, err := load(filename)
+	if err != nil {
+		return err
+	}
+	if err = viper.MergeConfigMap(v.AllSettings()); err != nil {
+		return common.InvalidConfigErr.WithTrueErr(err)
+	}
+	viper.Set("Zookeeper.Servers", c.Zookeeper.Servers)
+	loadedCfg = filename
+	current.Store(c)
+	return InitLogger(c.Log)
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+func writeConf(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_sample(t *testing.T) {
+	c, err := Load("../conf/conf-noSecret.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Zookeeper.Servers) != 1 || c.Zookeeper.Servers[0] != "your host" {
+		t.Fatalf("servers: %v", c.Zookeeper.Servers)
+	}
+	if c.Http.ReadTimeout != 15*time.Second || c.Zookeeper.CoolDown != 10*time.Second {
+		t.Fatalf("durations: %v %v", c.Http.ReadTimeout, c.Zookeeper.CoolDown)
+	}
+	if c.Generator.Epoch != common.Twepoch {
+		t.Fatalf("epoch: %d", c.Generator.Epoch)
+	}
+}
+
+func TestLoad_formats(t *testing.T) {
+	files := map[string]string{
+		"conf.json": `{"Center": {"Name": "zk"}, "Zookeeper": {"Servers": ["a:2181", "b:2181"]}, "Log": {"Level": "debug"}}`,
+		"conf.toml": "[Center]\nName = \"zk\"\n[Zookeeper]\nServers = [\"a:2181\", \"b:2181\"]\n[Log]\nLevel = \"debug\"\n",
+		"conf.yaml": "Center:\n  Name: zk\nZookeeper:\n  Servers: \"a:2181;b:2181\"\nLog:\n  Level: debug\n",
+	}
+	for name, content := range files {
+		c, err := Load(writeConf(t, name, content))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if strings.Join(c.Zookeeper.Servers, ",") != "a:2181,b:2181" || c.Log.Level != "debug" {
+			t.Fatalf("%s: %+v", name, c)
+		}
+		if c.Http.Listen != "0.0.0.0:8090" || c.App.DataCenterId != -1 {
+			t.Fatalf("%s: defaults not applied: %+v", name, c)
+		}
+	}
+}
+
+func TestLoad_env(t *testing.T) {
+	path := writeConf(t, "conf.yaml", "Log:\n  Level: INFO\n")
+	t.Setenv("SNOWFLAKE_LOG_LEVEL", "ERROR")
+	t.Setenv("SNOWFLAKE_ZOOKEEPER_SERVERS", "a:2181;b:2181")
+	t.Setenv("SNOWFLAKE_HTTP_READTIMEOUT", "2s")
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Log.Level != "ERROR" || len(c.Zookeeper.Servers) != 2 || c.Http.ReadTimeout != 2*time.Second {
+		t.Fatalf("env overrides not applied: %+v", c)
+	}
+}
+
+func TestLoad_validation(t *testing.T) {
+	path := writeConf(t, "conf.yaml", `
+Center:
+  Name: zk
+App:
+  DataCenterId: 99
+Log:
+  Level: loud
+Http:
+  Listen: "no-port"
+  RateLimit: -1
+`)
+	_, err := Load(path)
+	var ve *ValidationError
+	if !errors.As(err, &ve) || !errors.Is(err, common.InvalidConfigErr) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if len(ve.Problems) != 5 {
+		t.Fatalf("expected 5 problems, got %d: %v", len(ve.Problems), ve.Problems)
+	}
+}
+
+func TestInitConfig_missing(t *testing.T) {
+	if err := InitConfig(t.TempDir(), "missing.yaml"); !errors.Is(err, common.InvalidConfigErr) {
+		t.Fatalf("expected InvalidConfigErr, got %v", err)
+	}
+}
+
+func TestLoad_tags(t *testing.T) {
+	c, err := Load(writeConf(t, "conf.yaml", `
+Tags:
+  - Name: orders
+  - Name: users
+    SequenceBits: 6
+    ReservedBits: 4
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Tags) != 2 || c.Tags[0].Name != "orders" || c.Tags[1].SequenceBits != 6 || c.Tags[1].ReservedBits != 4 {
+		t.Fatalf("tags: %+v", c.Tags)
+	}
+
+	_, err = Load(writeConf(t, "conf.yaml", `
+Tags:
+  - Name: orders
+  - Name: orders
+  - Name: "a/b"
+  - Name: wide
+    SequenceBits: 40
+`))
+	var ve *ValidationError
+	if !errors.As(err, &ve) || len(ve.Problems) != 3 {
+		t.Fatalf("expected 3 problems, got %v", err)
+	}
+
+	old := &Config{Tags: []TagConfig{{Name: "orders"}}}
+	if changes := IdentityChanges(old, &Config{Tags: []TagConfig{{Name: "orders", SequenceBits: common.SequenceBits}, {Name: "users"}}}); len(changes) != 0 {
+		t.Fatalf("adding a tag is not an identity change: %v", changes)
+	}
+	if changes := IdentityChanges(old, &Config{Tags: []TagConfig{{Name: "orders", SequenceBits: 8}}}); len(changes) != 1 {
+		t.Fatalf("changing the layout of a tag: %v", changes)
+	}
+}
+
+func TestLoad_leaseMaxStale(t *testing.T) {
+	base := "Center:\n  Name: zk\nZookeeper:\n  Servers: [\"a:2181\"]\n  CoolDown: 10s\n  SessionTimeout: 3s\nGenerator:\n  LeaseCache: /tmp/lease.json\n"
+	if _, err := Load(writeConf(t, "conf.yaml", base+"  LeaseMaxStale: 7s\n")); err != nil {
+		t.Fatalf("within the safe window: %v", err)
+	}
+	var ve *ValidationError
+	if _, err := Load(writeConf(t, "conf.yaml", base+"  LeaseMaxStale: 10m\n")); !errors.As(err, &ve) || len(ve.Problems) != 1 {
+		t.Fatalf("LeaseMaxStale beyond CoolDown - SessionTimeout: %v", err)
+	}
+	if _, err := Load(writeConf(t, "conf.yaml", strings.Replace(base, "CoolDown: 10s", "CoolDown: 2s", 1)+"  LeaseMaxStale: 1s\n")); !errors.As(err, &ve) {
+		t.Fatalf("CoolDown shorter than SessionTimeout: %v", err)
+	}
+}
+
+func TestLoad_leaseTTL(t *testing.T) {
+	base := "Center:\n  Name: zk\nZookeeper:\n  Servers: [\"a:2181\"]\n  CoolDown: 10s\n  SessionTimeout: 3s\nLease:\n  Enabled: true\n"
+	if _, err := Load(writeConf(t, "conf.yaml", base+"  TTL: 7s\n")); err != nil {
+		t.Fatalf("within the safe window: %v", err)
+	}
+	var ve *ValidationError
+	if _, err := Load(writeConf(t, "conf.yaml", base+"  TTL: 30s\n")); !errors.As(err, &ve) || len(ve.Problems) != 1 {
+		t.Fatalf("TTL beyond CoolDown - SessionTimeout: %v", err)
+	}
+}
+
+func TestLoad_leaseMemBackend(t *testing.T) {
+	var ve *ValidationError
+	if _, err := Load(writeConf(t, "conf.yaml", "Lease:\n  Enabled: true\n")); !errors.As(err, &ve) || len(ve.Problems) != 1 {
+		t.Fatalf("lease server without zk should be rejected: %v", err)
+	}
+	if _, err := Load(writeConf(t, "conf.yaml", "Lease:\n  Enabled: true\n  UnsafeMemBackend: true\n")); err != nil {
+		t.Fatalf("explicit in-process allocator: %v", err)
+	}
+}
+
+func TestLoad_legacySeconds(t *testing.T) {
+	c, err := Load(writeConf(t, "conf.yaml", "Zookeeper:\n  SessionTimeout: 3\n  CoolDown: 10\nHttp:\n  ReadTimeout: 15\n  WriteTimeout: 15\n  IdleTimeout: 60\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Http.ReadTimeout != 15*time.Second || c.Http.IdleTimeout != time.Minute || c.Zookeeper.SessionTimeout != 3*time.Second || c.Zookeeper.CoolDown != 10*time.Second {
+		t.Fatalf("bare integers should be seconds: %+v %+v", c.Http, c.Zookeeper)
+	}
+	c, err = Load(writeConf(t, "conf.json", `{"Http": {"ReadTimeout": 15, "WriteTimeout": "500ms"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Http.ReadTimeout != 15*time.Second || c.Http.WriteTimeout != 500*time.Millisecond {
+		t.Fatalf("json: %+v", c.Http)
+	}
+	t.Setenv("SNOWFLAKE_HTTP_IDLETIMEOUT", "30")
+	if c, err = Load(writeConf(t, "conf.yaml", "Log:\n  Level: INFO\n")); err != nil || c.Http.IdleTimeout != 30*time.Second {
+		t.Fatalf("env: %v %v", c, err)
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/lypee/snowFlake/base"
+)
+
+var (
+	logMu     sync.Mutex
+	logWriter *base.RotateWriter
+	logPath   string
+	logRotate base.RotateOpt
+)
+
+// InitLogger 按 Log 配置替换全局 Logger: 配置了 Path 时写入该文件并按大小/时间切分,
+// 否则输出到标准输出
+func InitLogger(c LogConfig) error {
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	rotateOpt := base.RotateOpt{
+		MaxSize:    c.MaxSize << 20,
+		Interval:   c.RotateInterval,
+		MaxBackups: c.MaxBackups,
+		Compress:   c.Compress,
+	}
+	// 只修改了级别或格式时沿用已打开的文件
+	if logWriter != nil && logPath == c.Path && logRotate == rotateOpt {
+		base.SetLogger(base.NewLogger(logWriter, base.ParseLevel(c.Level), base.WithFormat(base.ParseFormat(c.Format))))
+		return nil
+	}
+
+	var out io.Writer = os.Stdout
+	var w *base.RotateWriter
+	if c.Path != "" {
+		var err error
+		if w, err = base.NewRotateWriter(c.Path, rotateOpt); err != nil {
+			return err
+		}
+		out = w
+	}
+	base.SetLogger(base.NewLogger(out, base.ParseLevel(c.Level), base.WithFormat(base.ParseFormat(c.Format))))
+
+	// SetLogger 返回时经由旧 Logger 的输出已经结束, 此时再关闭旧文件
+	old := logWriter
+	logWriter, logPath, logRotate = w, c.Path, rotateOpt
+	if old != nil {
+		return old.Close()
+	}
+	return nil
+}
+
+// CloseLogger 关闭 InitLogger 打开的日志文件, 退出前调用以等待历史文件压缩完成
+func CloseLogger() error {
+	logMu.Lock()
+	w := logWriter
+	logWriter = nil
+	logMu.Unlock()
+	if w == nil {
+		return nil
+	}
+	return w.Close()
+}
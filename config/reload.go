@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/lypee/snowFlake/base"
+	"github.com/lypee/snowFlake/common"
+)
+
+var (
+	reloadMu  sync.Mutex
+	loadedCfg string // InitConfig 加载的文件
+
+	watchOnce sync.Once
+
+	subMu     sync.Mutex
+	subSeq    int
+	listeners = map[int]func(old, new *Config){}
+)
+
+// OnChange 注册配置变更的回调, Reload 成功后调用, 多个回调之间的顺序不固定; 返回的函数用于取消注册
+func OnChange(fn func(old, new *Config)) (cancel func()) {
+	subMu.Lock()
+	defer subMu.Unlock()
+	subSeq++
+	id := subSeq
+	listeners[id] = fn
+	return func() {
+		subMu.Lock()
+		defer subMu.Unlock()
+		delete(listeners, id)
+	}
+}
+
+// Reload 重新读取 InitConfig 加载的文件并应用其中可在运行时修改的配置(日志、时钟回拨容忍度、指标、HTTP限流等)
+// 修改了 IdentityChanges 中的配置时返回 IdentityChangedErr, 当前配置保持不变, 需要重启才能生效
+func Reload() (*Config, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	if loadedCfg == "" {
+		return nil, common.InvalidConfigErr.WithTrueErr(fmt.Errorf("config not initialized"))
+	}
+	c, err := Load(loadedCfg)
+	if err != nil {
+		return nil, err
+	}
+	return c, apply(c)
+}
+
+// apply 调用方持有 reloadMu
+func apply(c *Config) error {
+	old := Current()
+	if old != nil {
+		if changes := IdentityChanges(old, c); len(changes) > 0 {
+			return common.IdentityChangedErr.WithTrueErr(fmt.Errorf("%s changed, restart required", strings.Join(changes, ", ")))
+		}
+		if old.Log != c.Log {
+			if err := InitLogger(c.Log); err != nil {
+				return err
+			}
+		}
+	}
+	current.Store(c)
+
+	subMu.Lock()
+	fns := make([]func(old, new *Config), 0, len(listeners))
+	for _, fn := range listeners {
+		fns = append(fns, fn)
+	}
+	subMu.Unlock()
+	if old != nil {
+		for _, fn := range fns {
+			fn(old, c)
+		}
+	}
+	return nil
+}
+
+// IdentityChanges 决定ID空间的配置(起始时间、数据中心、命名空间、分配器、已有业务的布局)中被修改的项
+// 运行中修改这些配置会破坏ID的唯一性
+func IdentityChanges(old, new *Config) []string {
+	var changes []string
+	if old.Generator.Epoch != new.Generator.Epoch {
+		changes = append(changes, "Generator.Epoch")
+	}
+	if old.App.DataCenterId != new.App.DataCenterId {
+		changes = append(changes, "App.DataCenterId")
+	}
+	if old.App.DataCenter != new.App.DataCenter {
+		changes = append(changes, "App.DataCenter")
+	}
+	if old.App.Name != new.App.Name {
+		changes = append(changes, "App.Name")
+	}
+	if old.Center.Name != new.Center.Name {
+		changes = append(changes, "Center.Name")
+	}
+	// 已有业务的布局不能修改, 新增的业务可以在运行中生效
+	layouts := make(map[string]TagConfig, len(old.Tags))
+	for _, t := range old.Tags {
+		layouts[t.Name] = t.withDefaults(old.Generator.Epoch)
+	}
+	for _, t := range new.Tags {
+		if l, ok := layouts[t.Name]; ok && l != t.withDefaults(new.Generator.Epoch) {
+			changes = append(changes, "Tags["+t.Name+"]")
+		}
+	}
+	return changes
+}
+
+// Watch 监听 InitConfig 加载的文件, 变更后自动 Reload; 失败时记录日志并保留当前配置
+func Watch() {
+	watchOnce.Do(func() {
+		reloadMu.Lock()
+		filename := loadedCfg
+		reloadMu.Unlock()
+		v := newViper()
+		v.SetConfigFile(filename)
+		if err := v.ReadInConfig(); err != nil {
+			base.WarningF("config watch err:[%+v], path:[%s]", err, filename)
+			return
+		}
+		v.OnConfigChange(func(e fsnotify.Event) {
+			if _, err := Reload(); err != nil {
+				base.ErrorF("config reload err:[%+v], path:[%s]", err, e.Name)
+				return
+			}
+			base.InfoF("config reloaded from %s", e.Name)
+		})
+		v.WatchConfig()
+	})
+}
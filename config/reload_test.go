@@ -0,0 +1,54 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lypee/snowFlake/base"
+	"github.com/lypee/snowFlake/common"
+)
+
+func TestReload(t *testing.T) {
+	old := base.GetLogger()
+	defer base.SetLogger(old)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "conf.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("App:\n  DataCenterId: 1\nGenerator:\n  MaxRollback: 5ms\nLog:\n  Level: INFO\n")
+	if err := InitConfig(dir, "conf.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	defer CloseLogger()
+
+	var got *Config
+	cancel := OnChange(func(_, c *Config) { got = c })
+	defer cancel()
+
+	write("App:\n  DataCenterId: 1\nGenerator:\n  MaxRollback: 20ms\nLog:\n  Level: ERROR\n")
+	if _, err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Generator.MaxRollback != 20*time.Millisecond || Current().Log.Level != "ERROR" {
+		t.Fatalf("reload not applied: %+v", got)
+	}
+	if base.DLogger.Enabled(base.WARNING) {
+		t.Fatal("log level change not applied")
+	}
+
+	got = nil
+	write("App:\n  DataCenterId: 2\nGenerator:\n  MaxRollback: 20ms\n")
+	if _, err := Reload(); !errors.Is(err, common.IdentityChangedErr) {
+		t.Fatalf("expected IdentityChangedErr, got %v", err)
+	}
+	if got != nil || Current().App.DataCenterId != 1 {
+		t.Fatal("identity change must not be applied")
+	}
+}
@@ -0,0 +1,54 @@
+package snowFlake
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lypee/snowFlake/common"
+	"github.com/lypee/snowFlake/config"
+)
+
+func TestSfWorker_ApplyConfig(t *testing.T) {
+	sf := newWorker(1, defaultWorkerOpt())
+	c := &config.Config{}
+	c.App.DataCenterId = 1
+	c.Generator.Epoch = common.Twepoch
+	c.Generator.MaxRollback = 30 * time.Millisecond
+	if err := sf.ApplyConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	if sf.maxRollback != 30*time.Millisecond {
+		t.Fatalf("maxRollback: %v", sf.maxRollback)
+	}
+
+	c.Generator.Epoch++
+	if err := sf.ApplyConfig(c); !errors.Is(err, common.IdentityChangedErr) {
+		t.Fatalf("epoch change: %v", err)
+	}
+	c.Generator.Epoch--
+	c.App.DataCenterId = 2
+	if err := sf.ApplyConfig(c); !errors.Is(err, common.IdentityChangedErr) {
+		t.Fatalf("dataCenter change: %v", err)
+	}
+}
+
+func TestRegistry_RegisterTags(t *testing.T) {
+	r, _ := newTestRegistry()
+	c := &config.Config{Tags: []config.TagConfig{{Name: "orders"}, {Name: "users", SequenceBits: 6, ReservedBits: 4}}}
+	c.Generator.Epoch = common.Twepoch - 1000
+	if err := r.RegisterTags(c); err != nil {
+		t.Fatal(err)
+	}
+	if g, ok := r.Get("orders"); !ok || g.Layout().Epoch != c.Generator.Epoch {
+		t.Fatalf("orders should use Generator.Epoch: %+v", g.Layout())
+	}
+	if g, ok := r.Get("users"); !ok || g.Layout().ReservedBits != 4 {
+		t.Fatal("users not registered")
+	}
+
+	c.Tags = append(c.Tags, config.TagConfig{Name: "orders", SequenceBits: 8})
+	if err := r.RegisterTags(c); !errors.Is(err, common.InvalidLayoutErr) {
+		t.Fatalf("layout change: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package snowFlake
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/viper"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+const (
+	// EnvDataCenterId 数据中心ID环境变量
+	EnvDataCenterId = "SNOWFLAKE_DATACENTER_ID"
+	// EnvDataCenterName 数据中心名称环境变量, 由分配器分配ID
+	EnvDataCenterName = "SNOWFLAKE_DATACENTER"
+
+	defaultDataCenterID = int64(1)
+)
+
+// resolveDataCenter 确定数据中心ID, 优先级:
+// WithDataCenterId > SNOWFLAKE_DATACENTER_ID > App.DataCenterId > 按名称分配(WithDataCenterName > SNOWFLAKE_DATACENTER > App.DataCenter) > 1
+// 返回的 name 不为空时需要由分配器按名称分配ID
+func (opt *workerOpt) resolveDataCenter() (id int64, name string, err error) {
+	switch {
+	case opt.dataCenterID >= 0:
+		id = opt.dataCenterID
+	case os.Getenv(EnvDataCenterId) != "":
+		id, err = strconv.ParseInt(os.Getenv(EnvDataCenterId), 10, 64)
+		if err != nil {
+			return 0, "", common.InvalidDataCenterErr.WithTrueErr(err)
+		}
+	case viper.IsSet("App.DataCenterId") && viper.GetInt64("App.DataCenterId") >= 0:
+		id = viper.GetInt64("App.DataCenterId")
+	default:
+		name = opt.dataCenterName
+		if name == "" {
+			name = os.Getenv(EnvDataCenterName)
+		}
+		if name == "" {
+			name = viper.GetString("App.DataCenter")
+		}
+		if name == "" {
+			id = defaultDataCenterID
+		}
+	}
+	if id < 0 || id > common.MaxDataCenterID {
+		return 0, "", common.InvalidDataCenterErr.WithTrueErr(fmt.Errorf("dataCenterId %d out of range [0, %d]", id, common.MaxDataCenterID))
+	}
+	return id, name, nil
+}
@@ -0,0 +1,57 @@
+package snowFlake
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+func TestWorkerOpt_resolveDataCenter(t *testing.T) {
+	opt := defaultWorkerOpt()
+	if id, name, err := opt.resolveDataCenter(); err != nil || id != defaultDataCenterID || name != "" {
+		t.Fatalf("default: %d %q %v", id, name, err)
+	}
+
+	WithDataCenterName("sh")(opt)
+	if id, name, _ := opt.resolveDataCenter(); id != 0 || name != "sh" {
+		t.Fatalf("by name: %d %q", id, name)
+	}
+
+	viper.Set("App.DataCenterId", 3)
+	defer viper.Set("App.DataCenterId", nil)
+	if id, _, _ := opt.resolveDataCenter(); id != 3 {
+		t.Fatalf("config: %d", id)
+	}
+
+	os.Setenv(EnvDataCenterId, "4")
+	defer os.Unsetenv(EnvDataCenterId)
+	if id, _, _ := opt.resolveDataCenter(); id != 4 {
+		t.Fatalf("env: %d", id)
+	}
+
+	WithDataCenterId(common.MaxDataCenterID + 1)(opt)
+	if _, _, err := opt.resolveDataCenter(); err == nil {
+		t.Fatal("expected out of range error")
+	}
+}
+
+func TestSfWorker_layout(t *testing.T) {
+	sf := newWorker(common.MaxDataCenterID, defaultWorkerOpt())
+	sf.workerID = common.MaxWorkerID
+	id, err := sf.NextID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dc := int64(id>>common.DataLeft) & common.MaxDataCenterID; dc != common.MaxDataCenterID {
+		t.Fatalf("dataCenterId: %d", dc)
+	}
+	if worker := int64(id>>common.WorkLeft) & common.MaxWorkerID; worker != common.MaxWorkerID {
+		t.Fatalf("workerId: %d", worker)
+	}
+	if seq := int64(id) & common.MaxSequence; seq != 0 {
+		t.Fatalf("sequence: %d", seq)
+	}
+}
@@ -0,0 +1,38 @@
+package snowFlake
+
+import (
+	"time"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+// IDParts ID 拆解后的各段
+type IDParts struct {
+	ID           uint64 `json:"id,string"`
+	Timestamp    int64  `json:"timestamp"` // 毫秒
+	DataCenterID int64  `json:"dataCenterId"`
+	WorkerID     int64  `json:"workerId"`
+	Sequence     int64  `json:"sequence"`
+	Reserved     int64  `json:"reserved,omitempty"` // 业务保留位, 见 Layout.ReservedBits
+}
+
+// Time 生成ID时的时间
+func (p IDParts) Time() time.Time {
+	return time.Unix(0, p.Timestamp*int64(time.Millisecond))
+}
+
+// Decode 按本worker的起始时间拆解 id
+func (w *SfWorker) Decode(id uint64) IDParts {
+	return DecodeWithEpoch(id, w.epoch)
+}
+
+// DecodeWithEpoch 按起始时间 epoch(毫秒) 拆解 id
+func DecodeWithEpoch(id uint64, epoch int64) IDParts {
+	return IDParts{
+		ID:           id,
+		Timestamp:    int64(id>>common.TimeLeft) + epoch,
+		DataCenterID: int64(id>>common.DataLeft) & common.MaxDataCenterID,
+		WorkerID:     int64(id>>common.WorkLeft) & common.MaxWorkerID,
+		Sequence:     int64(id) & common.MaxSequence,
+	}
+}
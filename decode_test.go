@@ -0,0 +1,28 @@
+package snowFlake
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSfWorker_Decode(t *testing.T) {
+	sf := newWorker(3, defaultWorkerOpt())
+	sf.workerID = 42
+	before := time.Now().Add(-time.Millisecond)
+	ids, err := sf.NextIDs(5)
+	if err != nil || len(ids) != 5 {
+		t.Fatalf("NextIDs: %v %v", ids, err)
+	}
+	for i, id := range ids {
+		p := sf.Decode(id)
+		if p.DataCenterID != 3 || p.WorkerID != 42 {
+			t.Fatalf("parts: %+v", p)
+		}
+		if p.Time().Before(before) || p.Time().After(time.Now()) {
+			t.Fatalf("time: %v", p.Time())
+		}
+		if i > 0 && id <= ids[i-1] {
+			t.Fatalf("ids not increasing: %v", ids)
+		}
+	}
+}
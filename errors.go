@@ -0,0 +1,16 @@
+package snowFlake
+
+import "github.com/lypee/snowFlake/common"
+
+// 发号相关的错误, 用 errors.Is 判断; 时钟回拨时可用 errors.As 取得 *common.ClockBackwardsError 获取回拨时长
+var (
+	ErrClockBackwards    error = common.ClockBackwardsErr
+	ErrSequenceExhausted error = common.SequenceExhaustedErr
+	ErrLeaseLost         error = common.LeaseConflictErr
+	ErrWorkerIDExhausted error = common.WorkerIdExhaustedErr
+	ErrInvalidLayout     error = common.InvalidLayoutErr
+	ErrWorkerClosed      error = common.WorkerClosedErr
+)
+
+// ClockBackwardsError 时钟回拨的详细信息
+type ClockBackwardsError = common.ClockBackwardsError
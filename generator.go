@@ -0,0 +1,9 @@
+package snowFlake
+
+// Generator 发号器的公共接口, SfWorker、号段模式和远程客户端都实现了它, 可以互相替换
+type Generator interface {
+	NextID() (uint64, error)
+	NextIDs(n int) ([]uint64, error)
+}
+
+var _ Generator = (*SfWorker)(nil)
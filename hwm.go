@@ -0,0 +1,86 @@
+package snowFlake
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lypee/snowFlake/base"
+)
+
+// highWaterMark 持久化到本地文件的时间戳上界, 类似数据库的序列缓存:
+// 每次预留 reserve 毫秒, 只有时间戳达到已持久化的上界时才同步写文件, 接近上界时提前异步续期
+// 重启后以文件中的值作为 lastStamp, 时钟回拨也不会发出重复的ID
+type highWaterMark struct {
+	path     string
+	reserve  int64 // 毫秒
+	mu       sync.Mutex
+	reserved int64 // 已持久化的上界, 原子读写
+	saving   int32
+	logger   base.Logger
+}
+
+type hwmState struct {
+	Stamp int64 `json:"stamp"`
+}
+
+func newHighWaterMark(path string, reserve int64) *highWaterMark {
+	if reserve <= 0 {
+		reserve = 1
+	}
+	return &highWaterMark{path: path, reserve: reserve}
+}
+
+// load 读取持久化的上界, 文件不存在时返回0
+func (h *highWaterMark) load() (int64, error) {
+	data, err := os.ReadFile(h.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	st := &hwmState{}
+	if err = json.Unmarshal(data, st); err != nil {
+		return 0, err
+	}
+	atomic.StoreInt64(&h.reserved, st.Stamp)
+	return st.Stamp, nil
+}
+
+// persist 把上界推进到 stamp 并写入文件
+func (h *highWaterMark) persist(stamp int64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if stamp <= atomic.LoadInt64(&h.reserved) {
+		return nil
+	}
+	data, err := json.Marshal(hwmState{Stamp: stamp})
+	if err != nil {
+		return err
+	}
+	if err = writeFileAtomic(h.path, data); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&h.reserved, stamp)
+	return nil
+}
+
+// ensure 保证 timeStamp 小于已持久化的上界, 调用方持有 SfWorker.mu
+func (h *highWaterMark) ensure(timeStamp int64) error {
+	reserved := atomic.LoadInt64(&h.reserved)
+	if timeStamp >= reserved {
+		return h.persist(timeStamp + h.reserve)
+	}
+	if timeStamp >= reserved-h.reserve/2 && atomic.CompareAndSwapInt32(&h.saving, 0, 1) {
+		go func() {
+			defer atomic.StoreInt32(&h.saving, 0)
+			if err := h.persist(timeStamp + h.reserve); err != nil {
+				base.OrDefault(h.logger).WarningF("highWaterMark.persist err:[%+v], path:[%s]", err, h.path)
+			}
+		}()
+	}
+	return nil
+}
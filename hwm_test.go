@@ -0,0 +1,83 @@
+package snowFlake
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lypee/snowFlake/base"
+)
+
+func TestSfWorker_highWaterMark(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hwm.json")
+	sf := newWorker(1, defaultWorkerOpt())
+	if err := sf.loadHighWaterMark(path, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sf.NextID(); err != nil {
+		t.Fatal(err)
+	}
+	reserved, err := newHighWaterMark(path, 0).load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reserved <= sf.lastStamp {
+		t.Fatalf("reserved %d should be ahead of last stamp %d", reserved, sf.lastStamp)
+	}
+
+	// 重启后在时钟越过上界之前拒绝发号
+	restarted := newWorker(1, defaultWorkerOpt())
+	if err = restarted.loadHighWaterMark(path, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = restarted.NextID(); err == nil {
+		t.Fatal("expected error before clock passes the high-water mark")
+	}
+	time.Sleep(time.Duration(reserved-restarted.getMilliSeconds()+1) * time.Millisecond)
+	id, err := restarted.NextID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restarted.lastStamp < reserved {
+		t.Fatalf("id %d issued below the high-water mark", id)
+	}
+}
+
+func TestHighWaterMark_ensure(t *testing.T) {
+	h := newHighWaterMark(filepath.Join(t.TempDir(), "hwm.json"), 100)
+	if err := h.ensure(1000); err != nil {
+		t.Fatal(err)
+	}
+	if reserved := atomic.LoadInt64(&h.reserved); reserved != 1100 {
+		t.Fatalf("reserved: %d", reserved)
+	}
+	// 超过一半后异步续期
+	h.ensure(1060)
+	for i := 0; i < 100 && atomic.LoadInt64(&h.reserved) == 1100; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if reserved := atomic.LoadInt64(&h.reserved); reserved != 1160 {
+		t.Fatalf("reserved after async renew: %d", reserved)
+	}
+}
+
+func TestSfWorker_logger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hwm.json")
+	ahead := newHighWaterMark(path, 0)
+	if err := ahead.persist(time.Now().UnixNano()/1e6 + 1000); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	opt := defaultWorkerOpt()
+	WithLogger(base.NewLogger(buf, base.DEBUG))(opt)
+	sf := newWorker(1, opt)
+	if err := sf.loadHighWaterMark(path, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "high-water mark") {
+		t.Fatalf("warning not written to injected logger: %q", buf.String())
+	}
+}
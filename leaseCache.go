@@ -0,0 +1,164 @@
+package snowFlake
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+// leaseState 本地缓存的租约, zk不可用时用于降级启动
+type leaseState struct {
+	WorkerID     int64 `json:"workerId"`
+	DataCenterID int64 `json:"dataCenterId"`
+	Stamp        int64 `json:"stamp"`    // 已发出ID的最大时间戳(毫秒)
+	LeasedAt     int64 `json:"leasedAt"` // 最近一次确认持有租约的时间(毫秒)
+}
+
+func loadLeaseState(path string) (*leaseState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	st := &leaseState{}
+	if err = json.Unmarshal(data, st); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func saveLeaseState(path string, st *leaseState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic 先写临时文件并落盘再改名, 避免进程崩溃时留下不完整的文件
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// startDegraded zk不可用时以本地缓存的租约启动, 缓存超过 leaseMaxStaleness 或数据中心不一致时返回 cause
+// leaseMaxStaleness 不能超过zk的安全窗口(冷却时间 - 会话超时), 否则其他主机可能已占用该ID; 超过时按安全窗口计算
+// 降级期间到达 LeasedAt + leaseMaxStaleness 后停止发号, 直到重新占用该ID
+func (w *SfWorker) startDegraded(opt *workerOpt, cause error) error {
+	if opt.leaseCachePath == "" {
+		return cause
+	}
+	st, err := loadLeaseState(opt.leaseCachePath)
+	if err != nil {
+		w.log().WarningF("loadLeaseState err:[%+v], path:[%s]", err, opt.leaseCachePath)
+		return cause
+	}
+	maxStaleness := opt.leaseMaxStaleness
+	if w.srv.zkSrv != nil {
+		if safe := w.srv.zkSrv.LeaseSafeWindow(); maxStaleness > safe {
+			w.log().WarningF("lease max staleness %v exceeds zk safe window %v, clamped", maxStaleness, safe)
+			maxStaleness = safe
+		}
+	}
+	staleness := time.Duration(w.getMilliSeconds()-st.LeasedAt) * time.Millisecond
+	if maxStaleness <= 0 || staleness > maxStaleness || st.DataCenterID != w.dataCenterID {
+		w.log().WarningF("lease cache unusable, staleness:[%v], dataCenterId:[%d]", staleness, st.DataCenterID)
+		return cause
+	}
+
+	w.workerID = st.WorkerID
+	w.lastStamp = st.Stamp
+	w.leasedAt = st.LeasedAt
+	w.leaseExpires = st.LeasedAt + maxStaleness.Milliseconds()
+	w.setDegraded(true)
+	w.log().WarningF("coordinator unavailable:[%+v], start degraded with cached workerId %d", cause, st.WorkerID)
+	go w.recoverLease(opt.leaseCacheInterval)
+	return nil
+}
+
+// recoverLease 降级期间定期尝试在zk上重新占用缓存的workerId
+// 成功后退出降级模式; 该ID已被其他主机占用时停止发号
+func (w *SfWorker) recoverLease(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case <-ticker.C:
+		}
+		claimed, err := w.srv.zkSrv.ClaimWorkerId(int(w.workerID))
+		if errors.Is(err, common.LeaseConflictErr) {
+			w.log().WarningF("workerId %d is held by another host, stop generating", w.workerID)
+			w.mu.Lock()
+			w.leaseLost = true
+			w.mu.Unlock()
+			leaseLostTotal.Add(1)
+			return
+		}
+		if err != nil || !claimed {
+			continue
+		}
+		w.mu.Lock()
+		w.leaseExpires = 0
+		w.mu.Unlock()
+		w.setDegraded(false)
+		w.startZkBackground()
+		w.log().InfoF("workerId %d lease recovered", w.workerID)
+		return
+	}
+}
+
+// persistLease 定期把租约和最大时间戳写入本地缓存
+func (w *SfWorker) persistLease(path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		w.saveLease(path)
+		select {
+		case <-w.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *SfWorker) saveLease(path string) {
+	stamp := w.heartbeatStamp()
+	w.mu.Lock()
+	// 只有zk确认过的租约才刷新 leasedAt, 降级期间不延长缓存的有效期
+	if !w.degraded && w.srv.zkSrv != nil {
+		if t := w.srv.zkSrv.LastReportTime(); !t.IsZero() {
+			w.leasedAt = t.UnixNano() / 1e6
+		}
+	}
+	st := &leaseState{
+		WorkerID:     w.workerID,
+		DataCenterID: w.dataCenterID,
+		Stamp:        stamp,
+		LeasedAt:     w.leasedAt,
+	}
+	w.mu.Unlock()
+	if err := saveLeaseState(path, st); err != nil {
+		w.log().WarningF("saveLeaseState err:[%+v], path:[%s]", err, path)
+	}
+}
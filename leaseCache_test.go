@@ -0,0 +1,97 @@
+package snowFlake
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lypee/snowFlake/server/zkServer"
+)
+
+func TestSfWorker_startDegraded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.json")
+	opt := defaultWorkerOpt()
+	WithLeaseCache(path, time.Minute)(opt)
+	opt.leaseCacheInterval = time.Hour
+	cause := errors.New("zk unavailable")
+
+	sf := newWorker(1, opt)
+	defer close(sf.stopCh)
+	if err := sf.startDegraded(opt, cause); err != cause {
+		t.Fatalf("without cache: %v", err)
+	}
+
+	now := sf.getMilliSeconds()
+	saveLeaseState(path, &leaseState{WorkerID: 17, DataCenterID: 1, Stamp: now + 50, LeasedAt: now})
+	if err := sf.startDegraded(opt, cause); err != nil {
+		t.Fatal(err)
+	}
+	status := sf.Status()
+	if !status.Degraded || status.WorkerID != 17 || status.LastStamp != now+50 {
+		t.Fatalf("status: %+v", status)
+	}
+	// 缓存的最大时间戳之前拒绝发号
+	if _, err := sf.NextID(); err == nil {
+		t.Fatal("expected error before clock passes cached stamp")
+	}
+
+	sf.saveLease(path)
+	st, err := loadLeaseState(path)
+	if err != nil || st.LeasedAt != now || st.Stamp < now+50 {
+		t.Fatalf("degraded worker should not extend lease: %+v, %v", st, err)
+	}
+}
+
+func TestSfWorker_startDegradedStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.json")
+	opt := defaultWorkerOpt()
+	WithLeaseCache(path, time.Second)(opt)
+	cause := errors.New("zk unavailable")
+
+	sf := newWorker(1, opt)
+	defer close(sf.stopCh)
+	stale := sf.getMilliSeconds() - time.Minute.Milliseconds()
+	saveLeaseState(path, &leaseState{WorkerID: 17, DataCenterID: 1, LeasedAt: stale})
+	if err := sf.startDegraded(opt, cause); err != cause {
+		t.Fatalf("stale cache: %v", err)
+	}
+	if sf.Status().Degraded {
+		t.Fatal("should not be degraded")
+	}
+}
+
+func TestSfWorker_startDegradedSafeWindow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lease.json")
+	opt := defaultWorkerOpt()
+	opt.center = "zk"
+	// 安全窗口为 1s, 配置的 1m 会被截断
+	WithConnOpts(zkServer.WithCoolDown(1500*time.Millisecond), zkServer.WithSessionTimeout(500*time.Millisecond))(opt)
+	WithLeaseCache(path, time.Minute)(opt)
+	opt.leaseCacheInterval = time.Hour
+	cause := errors.New("zk unavailable")
+
+	sf := newWorker(1, opt)
+	defer close(sf.stopCh)
+	now := sf.getMilliSeconds()
+	saveLeaseState(path, &leaseState{WorkerID: 17, DataCenterID: 1, LeasedAt: now - 1500})
+	if err := sf.startDegraded(opt, cause); err != cause {
+		t.Fatalf("cache older than the safe window: %v", err)
+	}
+
+	saveLeaseState(path, &leaseState{WorkerID: 17, DataCenterID: 1, LeasedAt: now - 900})
+	if err := sf.startDegraded(opt, cause); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sf.NextID(); err != nil {
+		t.Fatalf("NextID within the safe window: %v", err)
+	}
+	// 到达安全窗口后停止发号, 其他主机此时可能已占用该ID
+	time.Sleep(150 * time.Millisecond)
+	if _, err := sf.NextID(); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("NextID after the safe window: %v", err)
+	}
+	if !sf.Status().LeaseLost {
+		t.Fatal("status should report the lease as lost")
+	}
+}
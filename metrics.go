@@ -0,0 +1,37 @@
+package snowFlake
+
+import (
+	"expvar"
+	"sync"
+)
+
+// 通过 expvar 暴露的指标, 引入 net/http/pprof 或 expvar 的 handler 后可在 /debug/vars 查看
+var (
+	metrics = expvar.NewMap("snowflake")
+
+	degradedGauge  = new(expvar.Int) // 处于降级模式的worker数
+	leaseLostTotal = new(expvar.Int) // 租约被其他主机占用的次数
+
+	tagMetrics   = new(expvar.Map) // Registry 各业务的计数, key 为业务标识
+	tagMetricsMu sync.Mutex
+)
+
+func init() {
+	metrics.Set("degraded", degradedGauge)
+	metrics.Set("lease_lost_total", leaseLostTotal)
+	metrics.Set("tags", tagMetrics)
+}
+
+// tagMetricsFor 业务 tag 的计数: ids_total 已发出的ID个数, errors_total 发号失败的次数
+func tagMetricsFor(tag string) *expvar.Map {
+	tagMetricsMu.Lock()
+	defer tagMetricsMu.Unlock()
+	if m, ok := tagMetrics.Get(tag).(*expvar.Map); ok {
+		return m
+	}
+	m := new(expvar.Map)
+	m.Add("ids_total", 0)
+	m.Add("errors_total", 0)
+	tagMetrics.Set(tag, m)
+	return m
+}
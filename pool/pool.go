@@ -27,6 +27,13 @@ var ClientConnPool = sync.Pool{
 		if err != nil {
 			return nil
 		}
+		if user := viper.GetString("Zookeeper.Digest.User"); user != "" {
+			auth := user + ":" + viper.GetString("Zookeeper.Digest.Password")
+			if err = conn.AddAuth("digest", []byte(auth)); err != nil {
+				conn.Close()
+				return nil
+			}
+		}
 		return conn
 	},
 }
@@ -0,0 +1,311 @@
+package snowFlake
+
+import (
+	"expvar"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+// Layout 一个业务的ID布局: | 时间戳 | 数据中心ID | 工作机器ID | 序列号 | 保留位 |
+// 字段为0时取默认值, 保留位默认为0位
+type Layout struct {
+	Epoch            int64  `json:"epoch"` // 起始时间戳(毫秒)
+	WorkerIDBits     uint64 `json:"workerIdBits"`
+	DataCenterIDBits uint64 `json:"dataCenterIdBits"`
+	SequenceBits     uint64 `json:"sequenceBits"`
+	ReservedBits     uint64 `json:"reservedBits"` // 最低位留给业务自行填充(如分库分表的基因), 发号时为0
+}
+
+// DefaultLayout 与 SfWorker 相同的布局
+func DefaultLayout() Layout {
+	return Layout{
+		Epoch:            common.Twepoch,
+		WorkerIDBits:     common.WorkerIDBits,
+		DataCenterIDBits: common.DataCenterIDBits,
+		SequenceBits:     common.SequenceBits,
+	}
+}
+
+func (l Layout) withDefaults() Layout {
+	def := DefaultLayout()
+	if l.Epoch == 0 {
+		l.Epoch = def.Epoch
+	}
+	if l.WorkerIDBits == 0 {
+		l.WorkerIDBits = def.WorkerIDBits
+	}
+	if l.DataCenterIDBits == 0 {
+		l.DataCenterIDBits = def.DataCenterIDBits
+	}
+	if l.SequenceBits == 0 {
+		l.SequenceBits = def.SequenceBits
+	}
+	return l
+}
+
+// validate 校验时间戳位数足够, 且共用的 workerId 和数据中心ID放得下
+func (l Layout) validate(workerID, dataCenterID int64) error {
+	if err := common.ValidateLayout(l.WorkerIDBits, l.DataCenterIDBits, l.SequenceBits+l.ReservedBits,
+		l.Epoch, time.Now().UnixNano()/1e6); err != nil {
+		return err
+	}
+	if workerID > maxOf(l.WorkerIDBits) {
+		return common.InvalidLayoutErr.WithTrueErr(fmt.Errorf("workerId %d does not fit in %d bits", workerID, l.WorkerIDBits))
+	}
+	if dataCenterID > maxOf(l.DataCenterIDBits) {
+		return common.InvalidLayoutErr.WithTrueErr(fmt.Errorf("dataCenterId %d does not fit in %d bits", dataCenterID, l.DataCenterIDBits))
+	}
+	return nil
+}
+
+func (l Layout) compose(stamp, dataCenterID, workerID, sequence int64) uint64 {
+	workLeft := l.ReservedBits + l.SequenceBits
+	dataLeft := workLeft + l.WorkerIDBits
+	timeLeft := dataLeft + l.DataCenterIDBits
+	return uint64(stamp-l.Epoch)<<timeLeft | uint64(dataCenterID)<<dataLeft |
+		uint64(workerID)<<workLeft | uint64(sequence)<<l.ReservedBits
+}
+
+// Decode 按该布局拆解 id
+func (l Layout) Decode(id uint64) IDParts {
+	workLeft := l.ReservedBits + l.SequenceBits
+	dataLeft := workLeft + l.WorkerIDBits
+	timeLeft := dataLeft + l.DataCenterIDBits
+	return IDParts{
+		ID:           id,
+		Timestamp:    int64(id>>timeLeft) + l.Epoch,
+		DataCenterID: int64(id>>dataLeft) & maxOf(l.DataCenterIDBits),
+		WorkerID:     int64(id>>workLeft) & maxOf(l.WorkerIDBits),
+		Sequence:     int64(id>>l.ReservedBits) & maxOf(l.SequenceBits),
+		Reserved:     int64(id) & maxOf(l.ReservedBits),
+	}
+}
+
+func maxOf(bits uint64) int64 {
+	return int64(-1) ^ (int64(-1) << bits)
+}
+
+// Registry 按业务标识(如 orders、users)发号, 每个业务有独立的布局和序列号,
+// 共用同一个 SfWorker 的 workerId(租约)、时钟和时间戳上界; 该 worker 关闭或租约丢失后所有业务停止发号
+type Registry struct {
+	w    *SfWorker
+	mu   sync.RWMutex
+	tags map[string]*TagGenerator
+}
+
+func NewRegistry(w *SfWorker) *Registry {
+	return &Registry{w: w, tags: map[string]*TagGenerator{}}
+}
+
+// Register 登记业务 tag, 已用相同布局登记过时返回原来的发号器, 布局不同时返回 common.InvalidLayoutErr
+func (r *Registry) Register(tag string, l Layout) (*TagGenerator, error) {
+	if tag == "" || strings.Contains(tag, "/") {
+		return nil, common.NodeNameErr.WithTrueErr(fmt.Errorf("biz_tag %q", tag))
+	}
+	l = l.withDefaults()
+	st := r.w.Status()
+	if err := l.validate(st.WorkerID, st.DataCenterID); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if g, ok := r.tags[tag]; ok {
+		if g.layout != l {
+			return nil, common.InvalidLayoutErr.WithTrueErr(fmt.Errorf("biz_tag %q is registered with a different layout", tag))
+		}
+		return g, nil
+	}
+	g := &TagGenerator{
+		tag:          tag,
+		layout:       l,
+		w:            r.w,
+		workerID:     st.WorkerID,
+		dataCenterID: st.DataCenterID,
+		metrics:      tagMetricsFor(tag),
+	}
+	r.tags[tag] = g
+	return g, nil
+}
+
+// Get 取得已登记的业务, 未登记时 ok 为 false
+func (r *Registry) Get(tag string) (g *TagGenerator, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	g, ok = r.tags[tag]
+	return
+}
+
+// Tags 已登记的业务标识, 按字典序
+func (r *Registry) Tags() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tags := make([]string, 0, len(r.tags))
+	for tag := range r.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// TagStats 一个业务的发号统计
+type TagStats struct {
+	Tag       string `json:"tag"`
+	Layout    Layout `json:"layout"`
+	Issued    int64  `json:"issued"` // 已发出的ID个数
+	Errors    int64  `json:"errors"` // 发号失败的次数
+	LastStamp int64  `json:"lastStamp"`
+}
+
+// Stats 各业务的发号统计, 按业务标识排序; 同样的计数也通过 expvar 的 snowflake.tags 暴露
+func (r *Registry) Stats() []TagStats {
+	tags := r.Tags()
+	res := make([]TagStats, 0, len(tags))
+	for _, tag := range tags {
+		if g, ok := r.Get(tag); ok {
+			res = append(res, g.Stats())
+		}
+	}
+	return res
+}
+
+// Worker 共用的 worker
+func (r *Registry) Worker() *SfWorker {
+	return r.w
+}
+
+// TagGenerator 一个业务的发号器, 由 Registry.Register 创建
+type TagGenerator struct {
+	tag          string
+	layout       Layout
+	w            *SfWorker
+	workerID     int64
+	dataCenterID int64
+	metrics      *expvar.Map
+
+	mu        sync.Mutex
+	lastStamp int64
+	sequence  int64
+
+	issued int64 // atomic
+	errors int64 // atomic
+}
+
+var _ Generator = (*TagGenerator)(nil)
+
+func (g *TagGenerator) Tag() string {
+	return g.tag
+}
+
+func (g *TagGenerator) Layout() Layout {
+	return g.layout
+}
+
+func (g *TagGenerator) NextID() (uint64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id, err := g.nextID()
+	if err != nil {
+		g.count(0, err)
+		return 0, err
+	}
+	g.count(1, nil)
+	return id, nil
+}
+
+// NextIDs 一次生成 n 个ID, 出错时返回错误和已生成的部分
+func (g *TagGenerator) NextIDs(n int) ([]uint64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ids := make([]uint64, 0, n)
+	for i := 0; i < n; i++ {
+		id, err := g.nextID()
+		if err != nil {
+			g.count(len(ids), err)
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	g.count(n, nil)
+	return ids, nil
+}
+
+// Decode 按该业务的布局拆解 id
+func (g *TagGenerator) Decode(id uint64) IDParts {
+	return g.layout.Decode(id)
+}
+
+// Status 共用的 worker 的状态
+func (g *TagGenerator) Status() WorkerStatus {
+	return g.w.Status()
+}
+
+func (g *TagGenerator) Stats() TagStats {
+	g.mu.Lock()
+	lastStamp := g.lastStamp
+	g.mu.Unlock()
+	return TagStats{
+		Tag:       g.tag,
+		Layout:    g.layout,
+		Issued:    atomic.LoadInt64(&g.issued),
+		Errors:    atomic.LoadInt64(&g.errors),
+		LastStamp: lastStamp,
+	}
+}
+
+func (g *TagGenerator) count(n int, err error) {
+	if n > 0 {
+		atomic.AddInt64(&g.issued, int64(n))
+		g.metrics.Add("ids_total", int64(n))
+	}
+	if err != nil {
+		atomic.AddInt64(&g.errors, 1)
+		g.metrics.Add("errors_total", 1)
+	}
+}
+
+// nextID 调用方持有 g.mu; 时间戳取自共用的时钟, 序列号各业务独立
+func (g *TagGenerator) nextID() (uint64, error) {
+	stamp, err := g.w.stamp()
+	if err != nil {
+		return 0, err
+	}
+	// 共用时钟不会后退, stamp 不小于 g.lastStamp
+	stamp, sequence, err := nextSequence(stamp, g.lastStamp, g.sequence, maxOf(g.layout.SequenceBits), g.w.stamp)
+	if err != nil {
+		return 0, err
+	}
+	g.lastStamp, g.sequence = stamp, sequence
+	return g.layout.compose(stamp, g.dataCenterID, g.workerID, sequence), nil
+}
+
+// stamp Registry 共用的时钟: 检查租约和时钟回拨并推进时间戳上界, 返回的时间戳不会小于之前发出的任何ID
+func (w *SfWorker) stamp() (int64, error) {
+	w.closeMu.RLock()
+	defer w.closeMu.RUnlock()
+	if w.closed {
+		return 0, w.closedErr()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	timeStamp, err := w.now()
+	if err != nil {
+		return 0, err
+	}
+	if w.hwm != nil {
+		if err = w.hwm.ensure(timeStamp); err != nil {
+			return 0, err
+		}
+	}
+	w.lastStamp = timeStamp
+	return timeStamp, nil
+}
@@ -0,0 +1,174 @@
+package snowFlake
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+func newTestRegistry() (*Registry, *SfWorker) {
+	w := newWorker(3, defaultWorkerOpt())
+	w.workerID = 42
+	return NewRegistry(w), w
+}
+
+func TestRegistry_layouts(t *testing.T) {
+	r, _ := newTestRegistry()
+	orders, err := r.Register("orders", Layout{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	epoch := time.Now().Add(-time.Hour).UnixNano() / 1e6
+	users, err := r.Register("users", Layout{Epoch: epoch, WorkerIDBits: 8, SequenceBits: 1, ReservedBits: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now().Add(-time.Millisecond)
+	for _, g := range []*TagGenerator{orders, users} {
+		ids, err := g.NextIDs(20)
+		if err != nil {
+			t.Fatalf("%s: %v", g.Tag(), err)
+		}
+		for i, id := range ids {
+			p := g.Decode(id)
+			if p.WorkerID != 42 || p.DataCenterID != 3 || p.Reserved != 0 {
+				t.Fatalf("%s parts: %+v", g.Tag(), p)
+			}
+			if p.Time().Before(before) || p.Time().After(time.Now()) {
+				t.Fatalf("%s time: %v", g.Tag(), p.Time())
+			}
+			if i > 0 && id <= ids[i-1] {
+				t.Fatalf("%s ids not increasing: %v", g.Tag(), ids)
+			}
+		}
+	}
+	// 默认布局与 SfWorker 一致
+	id, _ := orders.NextID()
+	if p := DecodeWithEpoch(id, common.Twepoch); p.WorkerID != 42 || p.DataCenterID != 3 {
+		t.Fatalf("default layout: %+v", p)
+	}
+	// 保留位在最低位
+	id, _ = users.NextID()
+	if id&0xf != 0 {
+		t.Fatalf("reserved bits should be zero: %b", id)
+	}
+	if p := users.Decode(id | 0x5); p.Reserved != 5 || p.WorkerID != 42 {
+		t.Fatalf("decode with reserved bits: %+v", p)
+	}
+}
+
+func TestTagGenerator_sequenceExhausted(t *testing.T) {
+	r, w := newTestRegistry()
+	now := w.getMilliSeconds()
+	w.clock = func() int64 { return now }
+	g, err := r.Register("users", Layout{SequenceBits: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := g.NextIDs(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 时钟停滞, 等待超时后不修改序列号, 之后的调用也不会重复发出
+	for i := 0; i < 2; i++ {
+		if id, err := g.NextID(); !errors.Is(err, common.SequenceExhaustedErr) {
+			t.Fatalf("expected SequenceExhaustedErr, got %d %v", id, err)
+		}
+	}
+	w.clock = func() int64 { return now + 1 }
+	id, err := g.NextID()
+	if err != nil || id <= ids[3] {
+		t.Fatalf("NextID after the clock moves on: %d %v, last %d", id, err, ids[3])
+	}
+}
+
+func TestRegistry_register(t *testing.T) {
+	r, _ := newTestRegistry()
+	if _, err := r.Register("", Layout{}); !errors.Is(err, common.NodeNameErr) {
+		t.Fatalf("empty tag: %v", err)
+	}
+	// workerId 42 放不下
+	if _, err := r.Register("small", Layout{WorkerIDBits: 4}); !errors.Is(err, common.InvalidLayoutErr) {
+		t.Fatalf("workerId overflow: %v", err)
+	}
+	if _, err := r.Register("future", Layout{Epoch: time.Now().Add(time.Hour).UnixNano() / 1e6}); !errors.Is(err, common.InvalidLayoutErr) {
+		t.Fatalf("epoch in the future: %v", err)
+	}
+
+	g, err := r.Register("orders", Layout{SequenceBits: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := r.Register("orders", Layout{SequenceBits: 8}); err != nil || again != g {
+		t.Fatalf("register again: %v", err)
+	}
+	if _, err = r.Register("orders", Layout{SequenceBits: 9}); !errors.Is(err, common.InvalidLayoutErr) {
+		t.Fatalf("register with another layout: %v", err)
+	}
+	if got, ok := r.Get("orders"); !ok || got != g {
+		t.Fatal("Get orders")
+	}
+	if _, ok := r.Get("users"); ok {
+		t.Fatal("users is not registered")
+	}
+}
+
+func TestRegistry_sharedWorker(t *testing.T) {
+	r, w := newTestRegistry()
+	orders, _ := r.Register("orders", Layout{})
+	users, _ := r.Register("users", Layout{})
+	if _, err := orders.NextID(); err != nil {
+		t.Fatal(err)
+	}
+
+	w.mu.Lock()
+	w.leaseLost = true
+	w.mu.Unlock()
+	for _, g := range []*TagGenerator{orders, users} {
+		if _, err := g.NextID(); !errors.Is(err, ErrLeaseLost) {
+			t.Fatalf("%s with the lease lost: %v", g.Tag(), err)
+		}
+	}
+	w.mu.Lock()
+	w.leaseLost = false
+	w.mu.Unlock()
+
+	// 各业务的ID不小于 worker 已发出的时间戳
+	if _, err := w.NextID(); err != nil {
+		t.Fatal(err)
+	}
+	stamp := w.Status().LastStamp
+	id, err := users.NextID()
+	if err != nil || users.Decode(id).Timestamp < stamp {
+		t.Fatalf("users after the worker: %v %v", users.Decode(id), err)
+	}
+
+	w.Close(context.Background())
+	if _, err = users.NextID(); !errors.Is(err, ErrWorkerClosed) {
+		t.Fatalf("NextID after the worker closed: %v", err)
+	}
+}
+
+func TestRegistry_stats(t *testing.T) {
+	r, w := newTestRegistry()
+	g, _ := r.Register("payments", Layout{})
+	if _, err := g.NextIDs(7); err != nil {
+		t.Fatal(err)
+	}
+	w.Close(context.Background())
+	g.NextID()
+
+	st := r.Stats()
+	if len(st) != 1 || st[0].Tag != "payments" || st[0].Issued != 7 || st[0].Errors != 1 || st[0].LastStamp == 0 {
+		t.Fatalf("stats: %+v", st)
+	}
+	m, ok := tagMetrics.Get("payments").(*expvar.Map)
+	if !ok || m.Get("ids_total").String() == "0" || m.Get("errors_total").String() == "0" {
+		t.Fatalf("expvar: %v", tagMetrics.String())
+	}
+}
@@ -0,0 +1,242 @@
+// Package segment 号段模式(参考 Leaf-segment): 按业务标识从存储中批量取号, 生成稠密、大致递增的ID
+package segment
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	snowFlake "github.com/lypee/snowFlake"
+	"github.com/lypee/snowFlake/base"
+	"github.com/lypee/snowFlake/common"
+)
+
+type segmentOpt struct {
+	preloadRatio float64       // 当前号段已使用该比例后预加载下一个号段
+	duration     time.Duration // 期望每个号段的使用时长, 据此调整步长
+	maxStep      int64
+	loadTimeout  time.Duration // 单次从存储取号段的超时, 也是号段用完后等待加载的最长时间
+	logger       base.Logger
+}
+
+func defaultSegmentOpt() *segmentOpt {
+	return &segmentOpt{
+		preloadRatio: 0.1,
+		duration:     15 * time.Minute,
+		maxStep:      1000000,
+		loadTimeout:  3 * time.Second,
+	}
+}
+
+type SegmentOptFunc func(opt *segmentOpt)
+
+// WithPreloadRatio 当前号段已使用 ratio 比例后在后台加载下一个号段, 默认0.1
+func WithPreloadRatio(ratio float64) SegmentOptFunc {
+	return func(opt *segmentOpt) {
+		opt.preloadRatio = ratio
+	}
+}
+
+// WithDynamicStep 号段用完的时间短于 d 时步长翻倍(不超过 maxStep), 长于 2d 时减半(不低于基础步长)
+func WithDynamicStep(d time.Duration, maxStep int64) SegmentOptFunc {
+	return func(opt *segmentOpt) {
+		opt.duration = d
+		opt.maxStep = maxStep
+	}
+}
+
+// WithLoadTimeout 从存储取号段的超时
+func WithLoadTimeout(d time.Duration) SegmentOptFunc {
+	return func(opt *segmentOpt) {
+		opt.loadTimeout = d
+	}
+}
+
+func WithLogger(l base.Logger) SegmentOptFunc {
+	return func(opt *segmentOpt) {
+		opt.logger = l
+	}
+}
+
+// buffer 一个号段的使用情况
+type buffer struct {
+	next, max int64
+	size      int64
+}
+
+func (b *buffer) remaining() int64 {
+	return b.max - b.next
+}
+
+// Generator 一个业务标识的号段发号器, 双缓冲: 当前号段用到一定比例时在后台加载下一个号段
+// ID 在单个进程内递增, 多个进程共用一个 tag 时各自取不同的号段, 只保证唯一
+type Generator struct {
+	store Store
+	tag   string
+	opt   *segmentOpt
+
+	mu        sync.Mutex
+	cur, next *buffer
+	loading   bool
+	loaded    chan struct{} // 当前的加载完成时关闭
+	loadErr   error
+	step      int64 // 下次申请的步长, 0表示使用存储中的基础步长
+	baseStep  int64
+	loadedAt  time.Time // 上次加载完成的时间
+	closed    bool
+}
+
+var _ snowFlake.Generator = (*Generator)(nil)
+
+// New 创建 tag 的发号器并同步加载第一个号段, tag 不存在时返回 common.UnknownBizTagErr
+func New(store Store, tag string, ofs ...SegmentOptFunc) (*Generator, error) {
+	opt := defaultSegmentOpt()
+	for _, of := range ofs {
+		of(opt)
+	}
+	g := &Generator{store: store, tag: tag, opt: opt}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.startLoad()
+	if err := g.wait(); err != nil {
+		return nil, err
+	}
+	g.cur, g.next = g.next, nil
+	return g, nil
+}
+
+func (g *Generator) log() base.Logger {
+	return base.OrDefault(g.opt.logger)
+}
+
+func (g *Generator) Tag() string {
+	return g.tag
+}
+
+func (g *Generator) NextID() (uint64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.nextID()
+}
+
+// NextIDs 一次取 n 个ID, 出错时返回错误和已取得的部分
+func (g *Generator) NextIDs(n int) ([]uint64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ids := make([]uint64, 0, n)
+	for i := 0; i < n; i++ {
+		id, err := g.nextID()
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Close 停止发号, 已取出的号段剩余部分被丢弃
+func (g *Generator) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.closed = true
+	return nil
+}
+
+// nextID 调用方持有 g.mu
+func (g *Generator) nextID() (uint64, error) {
+	for {
+		if g.closed {
+			return 0, common.WorkerClosedErr
+		}
+		if g.cur.remaining() > 0 {
+			id := g.cur.next
+			g.cur.next++
+			if g.next == nil && !g.loading && g.cur.remaining() < int64(float64(g.cur.size)*(1-g.opt.preloadRatio)) {
+				g.startLoad()
+			}
+			return uint64(id), nil
+		}
+		if g.next != nil {
+			g.cur, g.next = g.next, nil
+			continue
+		}
+		if !g.loading {
+			g.startLoad()
+		}
+		if err := g.wait(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// startLoad 在后台加载下一个号段, 调用方持有 g.mu
+func (g *Generator) startLoad() {
+	g.loading = true
+	g.loadErr = nil
+	loaded := make(chan struct{})
+	g.loaded = loaded
+	step := g.step
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), g.opt.loadTimeout)
+		r, err := g.store.Allocate(ctx, g.tag, step)
+		cancel()
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		defer close(loaded)
+		g.loading = false
+		if err != nil {
+			g.loadErr = err
+			g.log().Warning("load segment fail", "tag", g.tag, "err", err)
+			return
+		}
+		g.next = &buffer{next: r.Min, max: r.Max, size: r.Max - r.Min}
+		g.adjustStep(r)
+	}()
+}
+
+// wait 等待当前的加载完成, 调用方持有 g.mu, 等待期间释放
+func (g *Generator) wait() error {
+	loaded := g.loaded
+	g.mu.Unlock()
+	var timeout bool
+	select {
+	case <-loaded:
+	case <-time.After(g.opt.loadTimeout):
+		timeout = true
+	}
+	g.mu.Lock()
+	if timeout {
+		return common.SegmentNotReadyErr.WithTrueErr(errors.New("timed out waiting for the next segment"))
+	}
+	if g.next == nil && g.loadErr != nil {
+		return g.loadErr
+	}
+	return nil
+}
+
+// adjustStep 按上一个号段的使用时长调整下次的步长, 调用方持有 g.mu
+func (g *Generator) adjustStep(r Range) {
+	now := time.Now()
+	size := r.Max - r.Min
+	g.baseStep = r.Step
+	step := size
+	if !g.loadedAt.IsZero() && g.opt.duration > 0 {
+		switch elapsed := now.Sub(g.loadedAt); {
+		case elapsed < g.opt.duration && size*2 <= g.opt.maxStep:
+			step = size * 2
+		case elapsed >= 2*g.opt.duration && size/2 >= g.baseStep:
+			step = size / 2
+		}
+	}
+	if step != size {
+		g.log().Info("segment step adjusted", "tag", g.tag, "from", size, "to", step)
+	}
+	g.step = step
+	if g.step <= g.baseStep {
+		g.step = 0
+	}
+	g.loadedAt = now
+}
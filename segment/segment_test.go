@@ -0,0 +1,207 @@
+package segment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+// memStore 内存中的 leaf_alloc 表
+type memStore struct {
+	mu     sync.Mutex
+	maxId  map[string]int64
+	step   map[string]int64
+	steps  []int64 // 每次分配的实际步长
+	err    error
+	delay  time.Duration
+	allocs int
+}
+
+func newMemStore(tag string, step int64) *memStore {
+	return &memStore{maxId: map[string]int64{tag: 1}, step: map[string]int64{tag: step}}
+}
+
+func (s *memStore) Allocate(ctx context.Context, tag string, step int64) (Range, error) {
+	if s.delay > 0 {
+		time.Sleep(s.delay)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return Range{}, s.err
+	}
+	if _, ok := s.maxId[tag]; !ok {
+		return Range{}, common.UnknownBizTagErr
+	}
+	if step <= 0 {
+		step = s.step[tag]
+	}
+	s.maxId[tag] += step
+	s.allocs++
+	s.steps = append(s.steps, step)
+	return Range{Min: s.maxId[tag] - step, Max: s.maxId[tag], Step: s.step[tag]}, nil
+}
+
+func (s *memStore) allocCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.allocs
+}
+
+func TestGenerator_dense(t *testing.T) {
+	store := newMemStore("order", 100)
+	g, err := New(store, "order", WithDynamicStep(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	for want := uint64(1); want <= 1000; want++ {
+		id, err := g.NextID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != want {
+			t.Fatalf("got %d, want %d", id, want)
+		}
+	}
+	ids, err := g.NextIDs(50)
+	if err != nil || ids[0] != 1001 || ids[49] != 1050 {
+		t.Fatalf("NextIDs: %v %v", err, ids)
+	}
+	// 号段用完之前已预加载下一个
+	if n := store.allocCount(); n < 11 || n > 12 {
+		t.Fatalf("allocations: %d", n)
+	}
+}
+
+func TestGenerator_preload(t *testing.T) {
+	store := newMemStore("order", 100)
+	g, err := New(store, "order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	for i := 0; i < 20; i++ {
+		g.NextID()
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		g.mu.Lock()
+		ready := g.next != nil
+		g.mu.Unlock()
+		if ready {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("next segment should be preloaded after 10% is used")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGenerator_dynamicStep(t *testing.T) {
+	store := newMemStore("order", 100)
+	g, err := New(store, "order", WithDynamicStep(time.Hour, 400))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	if _, err = g.NextIDs(2000); err != nil {
+		t.Fatal(err)
+	}
+	store.mu.Lock()
+	steps := fmt.Sprint(store.steps)
+	store.mu.Unlock()
+	// 号段很快用完: 从第三次申请起步长翻倍直到上限(第二个号段在第一个加载后立即预加载)
+	if want := "[100 100 200 400 400"; len(steps) < len(want) || steps[:len(want)] != want {
+		t.Fatalf("steps: %s", steps)
+	}
+}
+
+func TestGenerator_errors(t *testing.T) {
+	if _, err := New(newMemStore("order", 10), "user"); !errors.Is(err, common.UnknownBizTagErr) {
+		t.Fatalf("unknown tag: %v", err)
+	}
+
+	store := newMemStore("order", 10)
+	g, err := New(store, "order", WithDynamicStep(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 等待预加载完成后再注入错误
+	g.NextID()
+	time.Sleep(20 * time.Millisecond)
+	store.mu.Lock()
+	store.err = errors.New("db down")
+	store.mu.Unlock()
+	if _, err = g.NextIDs(30); err == nil || err.Error() != "db down" {
+		t.Fatalf("NextIDs with the store down: %v", err)
+	}
+	store.mu.Lock()
+	store.err = nil
+	store.mu.Unlock()
+	if _, err = g.NextID(); err != nil {
+		t.Fatalf("NextID after the store recovered: %v", err)
+	}
+
+	g.Close()
+	if _, err = g.NextID(); !errors.Is(err, common.WorkerClosedErr) {
+		t.Fatalf("NextID after Close: %v", err)
+	}
+}
+
+func TestGenerator_loadTimeout(t *testing.T) {
+	store := newMemStore("order", 5)
+	g, err := New(store, "order", WithDynamicStep(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.opt.loadTimeout = 20 * time.Millisecond
+	store.delay = 200 * time.Millisecond
+	if _, err = g.NextIDs(20); !errors.Is(err, common.SegmentNotReadyErr) {
+		t.Fatalf("NextIDs with a slow store: %v", err)
+	}
+}
+
+func TestGenerator_concurrent(t *testing.T) {
+	store := newMemStore("order", 50)
+	g, err := New(store, "order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = map[uint64]bool{}
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				id, err := g.NextID()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("duplicate id %d", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,119 @@
+package segment
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+// Range 一个号段 [Min, Max)
+type Range struct {
+	Min  int64
+	Max  int64
+	Step int64 // 存储中为该业务配置的基础步长, 动态步长不会低于它
+}
+
+// Store 号段的持久化存储, 每次分配都原子地把 max_id 增加 step
+type Store interface {
+	// Allocate 为 tag 分配下一个号段, step 为0时使用存储中的基础步长; tag 不存在时返回 common.UnknownBizTagErr
+	Allocate(ctx context.Context, tag string, step int64) (Range, error)
+}
+
+// Schema MySQL 的建表语句, 其他数据库按需调整类型
+//
+//	INSERT INTO leaf_alloc(biz_tag, max_id, step) VALUES ('order', 1, 2000);
+const Schema = `CREATE TABLE IF NOT EXISTS leaf_alloc (
+  biz_tag     VARCHAR(128) NOT NULL,
+  max_id      BIGINT       NOT NULL DEFAULT 1,
+  step        INT          NOT NULL,
+  description VARCHAR(256) DEFAULT NULL,
+  update_time TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (biz_tag)
+)`
+
+type sqlOpt struct {
+	table  string
+	dollar bool // 占位符使用 $1, $2 (PostgreSQL), 默认为 ?
+}
+
+type SQLOptFunc func(opt *sqlOpt)
+
+// WithTable 号段表名, 默认 leaf_alloc
+func WithTable(name string) SQLOptFunc {
+	return func(opt *sqlOpt) {
+		opt.table = name
+	}
+}
+
+// WithDollarPlaceholders 使用 PostgreSQL 风格的占位符
+func WithDollarPlaceholders() SQLOptFunc {
+	return func(opt *sqlOpt) {
+		opt.dollar = true
+	}
+}
+
+// SQLStore 基于 database/sql 的号段存储, 在一个事务中 UPDATE max_id 后读回
+type SQLStore struct {
+	db        *sql.DB
+	updateDef string // 按基础步长
+	updateBy  string // 按指定步长
+	query     string
+}
+
+func NewSQLStore(db *sql.DB, ofs ...SQLOptFunc) *SQLStore {
+	opt := &sqlOpt{table: "leaf_alloc"}
+	for _, of := range ofs {
+		of(opt)
+	}
+	p := func(i int) string {
+		if opt.dollar {
+			return fmt.Sprintf("$%d", i)
+		}
+		return "?"
+	}
+	return &SQLStore{
+		db:        db,
+		updateDef: fmt.Sprintf("UPDATE %s SET max_id = max_id + step WHERE biz_tag = %s", opt.table, p(1)),
+		updateBy:  fmt.Sprintf("UPDATE %s SET max_id = max_id + %s WHERE biz_tag = %s", opt.table, p(1), p(2)),
+		query:     fmt.Sprintf("SELECT max_id, step FROM %s WHERE biz_tag = %s", opt.table, p(1)),
+	}
+}
+
+func (s *SQLStore) Allocate(ctx context.Context, tag string, step int64) (r Range, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return r, common.OpErr.WithTrueErr(err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var res sql.Result
+	if step > 0 {
+		res, err = tx.ExecContext(ctx, s.updateBy, step, tag)
+	} else {
+		res, err = tx.ExecContext(ctx, s.updateDef, tag)
+	}
+	if err != nil {
+		return r, common.OpErr.WithTrueErr(err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return r, common.UnknownBizTagErr.WithTrueErr(fmt.Errorf("biz_tag %q", tag))
+	}
+	var maxId int64
+	if err = tx.QueryRowContext(ctx, s.query, tag).Scan(&maxId, &r.Step); err != nil {
+		return r, common.OpErr.WithTrueErr(err)
+	}
+	if err = tx.Commit(); err != nil {
+		return r, common.OpErr.WithTrueErr(err)
+	}
+	if step <= 0 {
+		step = r.Step
+	}
+	r.Min, r.Max = maxId-step, maxId
+	return r, nil
+}
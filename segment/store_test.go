@@ -0,0 +1,80 @@
+package segment
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+func TestSQLStore_Allocate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store := NewSQLStore(db)
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE leaf_alloc SET max_id = max_id + step WHERE biz_tag = ?")).
+		WithArgs("order").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT max_id, step FROM leaf_alloc WHERE biz_tag = ?")).
+		WithArgs("order").WillReturnRows(sqlmock.NewRows([]string{"max_id", "step"}).AddRow(2001, 2000))
+	mock.ExpectCommit()
+	r, err := store.Allocate(ctx, "order", 0)
+	if err != nil || r != (Range{Min: 1, Max: 2001, Step: 2000}) {
+		t.Fatalf("Allocate: %+v %v", r, err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE leaf_alloc SET max_id = max_id + ? WHERE biz_tag = ?")).
+		WithArgs(4000, "order").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT max_id, step").
+		WithArgs("order").WillReturnRows(sqlmock.NewRows([]string{"max_id", "step"}).AddRow(6001, 2000))
+	mock.ExpectCommit()
+	r, err = store.Allocate(ctx, "order", 4000)
+	if err != nil || r != (Range{Min: 2001, Max: 6001, Step: 2000}) {
+		t.Fatalf("Allocate with step: %+v %v", r, err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE leaf_alloc").WithArgs("user").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	if _, err = store.Allocate(ctx, "user", 0); !errors.Is(err, common.UnknownBizTagErr) {
+		t.Fatalf("unknown tag: %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE leaf_alloc").WithArgs("order").WillReturnError(errors.New("deadlock"))
+	mock.ExpectRollback()
+	if _, err = store.Allocate(ctx, "order", 0); !errors.Is(err, common.OpErr) {
+		t.Fatalf("exec error: %v", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSQLStore_placeholders(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store := NewSQLStore(db, WithTable("seg"), WithDollarPlaceholders())
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE seg SET max_id = max_id + $1 WHERE biz_tag = $2")).
+		WithArgs(10, "order").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT max_id, step FROM seg WHERE biz_tag = $1")).
+		WithArgs("order").WillReturnRows(sqlmock.NewRows([]string{"max_id", "step"}).AddRow(11, 10))
+	mock.ExpectCommit()
+	if _, err = store.Allocate(context.Background(), "order", 10); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,28 @@
+package segment
+
+import (
+	"context"
+
+	"github.com/lypee/snowFlake/server/zkServer"
+)
+
+// ZkStore 基于 zk 计数节点的号段存储, 每个业务标识一个持久节点 <appRootPath>/_segment/<tag>,
+// 用节点版本做 compare-and-set; 复用 ZkServer 的连接和命名空间, tag 需先用 ZkServer.CreateSegment 登记
+type ZkStore struct {
+	srv *zkServer.ZkServer
+}
+
+func NewZkStore(srv *zkServer.ZkServer) *ZkStore {
+	return &ZkStore{srv: srv}
+}
+
+func (s *ZkStore) Allocate(ctx context.Context, tag string, step int64) (Range, error) {
+	maxId, base, err := s.srv.AllocSegment(ctx, tag, step)
+	if err != nil {
+		return Range{}, err
+	}
+	if step <= 0 {
+		step = base
+	}
+	return Range{Min: maxId - step, Max: maxId, Step: base}, nil
+}
@@ -0,0 +1,155 @@
+// Package sequence 按key计数的序列号, 如每个商户的发票号: 从存储批量预留区间后在本地递增,
+// 区间在存储中持久化后才会发出其中的值, 进程崩溃后未用完的部分被跳过而不会重复发出
+package sequence
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/lypee/snowFlake/base"
+	"github.com/lypee/snowFlake/common"
+)
+
+type sequenceOpt struct {
+	batch   int64 // 每次从存储预留的个数
+	maxKeys int   // 最多缓存区间的key个数
+	logger  base.Logger
+}
+
+type SequenceOptFunc func(opt *sequenceOpt)
+
+// WithBatch 每次从存储预留 n 个值, 默认100; 越大存储的写入越少, 崩溃后跳过的值也越多
+func WithBatch(n int64) SequenceOptFunc {
+	return func(opt *sequenceOpt) {
+		opt.batch = n
+	}
+}
+
+// WithMaxKeys 最多缓存 n 个key的区间, 默认10000; 超出时淘汰最久未使用的key, 其区间中未发出的值被跳过,
+// 再次使用时重新从存储预留
+func WithMaxKeys(n int) SequenceOptFunc {
+	return func(opt *sequenceOpt) {
+		opt.maxKeys = n
+	}
+}
+
+func WithLogger(l base.Logger) SequenceOptFunc {
+	return func(opt *sequenceOpt) {
+		opt.logger = l
+	}
+}
+
+// block 一个key已预留的区间 (next-1, max]
+type block struct {
+	key       string
+	mu        sync.Mutex
+	next, max int64
+}
+
+// KeyedSequence 按key计数, 每个key的值从1开始, 唯一且在本进程内递增;
+// 多个进程共用一个key时各自预留不同的区间, 只保证不重复, 进程之间不保证先发出的值更小
+type KeyedSequence struct {
+	store Store
+	opt   *sequenceOpt
+
+	mu     sync.Mutex
+	keys   map[string]*list.Element // 值为 *block
+	lru    *list.List               // 最近使用的在前
+	closed bool
+}
+
+func New(store Store, ofs ...SequenceOptFunc) *KeyedSequence {
+	opt := &sequenceOpt{batch: 100, maxKeys: 10000}
+	for _, of := range ofs {
+		of(opt)
+	}
+	if opt.batch < 1 {
+		opt.batch = 1
+	}
+	if opt.maxKeys < 1 {
+		opt.maxKeys = 1
+	}
+	return &KeyedSequence{store: store, opt: opt, keys: map[string]*list.Element{}, lru: list.New()}
+}
+
+func (s *KeyedSequence) log() base.Logger {
+	return base.OrDefault(s.opt.logger)
+}
+
+// Next 取 key 的下一个值
+func (s *KeyedSequence) Next(ctx context.Context, key string) (int64, error) {
+	vals, err := s.NextN(ctx, key, 1)
+	if err != nil {
+		return 0, err
+	}
+	return vals[0], nil
+}
+
+// NextN 一次取 key 的 n 个值, 递增但跨区间时不一定连续; 出错时不返回任何值, 已预留的区间留给下次使用
+func (s *KeyedSequence) NextN(ctx context.Context, key string, n int) ([]int64, error) {
+	if n < 1 {
+		return nil, common.OpErr.WithTrueErr(fmt.Errorf("n %d must be positive", n))
+	}
+	b, err := s.block(key)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	vals := make([]int64, 0, n)
+	next, max := b.next, b.max
+	for len(vals) < n {
+		if next > max {
+			// 不够时一次预留剩余需要的个数, 至少 batch 个
+			want := int64(n - len(vals))
+			if want < s.opt.batch {
+				want = s.opt.batch
+			}
+			newMax, err := s.store.Reserve(ctx, key, want)
+			if err != nil {
+				s.log().Warning("reserve sequence fail", "key", key, "err", err)
+				return nil, err
+			}
+			// 存储返回的区间总是在之前预留的区间之后, 所以值保持递增
+			next, max = newMax-want+1, newMax
+			b.next, b.max = next, max
+		}
+		vals = append(vals, next)
+		next++
+	}
+	b.next = next
+	return vals, nil
+}
+
+// Close 停止发号, 已预留但未发出的值被跳过
+func (s *KeyedSequence) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+// block 返回 key 的区间, 缓存的key超过 maxKeys 时淘汰最久未使用的;
+// 被淘汰的区间上进行中的调用照常完成, 之后的调用使用新的区间
+func (s *KeyedSequence) block(key string) (*block, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, common.WorkerClosedErr
+	}
+	if e, ok := s.keys[key]; ok {
+		s.lru.MoveToFront(e)
+		return e.Value.(*block), nil
+	}
+	b := &block{key: key, next: 1}
+	s.keys[key] = s.lru.PushFront(b)
+	if s.lru.Len() > s.opt.maxKeys {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.keys, oldest.Value.(*block).key)
+	}
+	return b, nil
+}
@@ -0,0 +1,158 @@
+package sequence
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+// countingStore 记录 Reserve 的调用次数, 可注入错误
+type countingStore struct {
+	*MemStore
+	mu       sync.Mutex
+	reserves int
+	err      error
+}
+
+func (s *countingStore) Reserve(ctx context.Context, key string, n int64) (int64, error) {
+	s.mu.Lock()
+	err := s.err
+	s.reserves++
+	s.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	return s.MemStore.Reserve(ctx, key, n)
+}
+
+func TestKeyedSequence_batch(t *testing.T) {
+	store := &countingStore{MemStore: NewMemStore()}
+	s := New(store, WithBatch(10))
+	ctx := context.Background()
+
+	for want := int64(1); want <= 25; want++ {
+		v, err := s.Next(ctx, "m1")
+		if err != nil || v != want {
+			t.Fatalf("Next: %d %v, want %d", v, err, want)
+		}
+	}
+	if store.reserves != 3 {
+		t.Fatalf("reserves: %d", store.reserves)
+	}
+	// 各key独立计数
+	if v, _ := s.Next(ctx, "m2"); v != 1 {
+		t.Fatalf("m2: %d", v)
+	}
+	// 超过 batch 时一次预留剩余需要的个数
+	vals, err := s.NextN(ctx, "m1", 30)
+	if err != nil || len(vals) != 30 || vals[0] != 26 || vals[29] != 55 {
+		t.Fatalf("NextN: %v %v", vals, err)
+	}
+	if _, err = s.NextN(ctx, "m1", 0); err == nil {
+		t.Fatal("NextN(0) should fail")
+	}
+}
+
+func TestKeyedSequence_maxKeys(t *testing.T) {
+	store := &countingStore{MemStore: NewMemStore()}
+	s := New(store, WithBatch(10), WithMaxKeys(2))
+	ctx := context.Background()
+
+	s.Next(ctx, "m1")
+	s.Next(ctx, "m2")
+	s.Next(ctx, "m1")
+	// m2 最久未使用, 被淘汰
+	s.Next(ctx, "m3")
+	if len(s.keys) != 2 || s.lru.Len() != 2 || s.keys["m2"] != nil {
+		t.Fatalf("cached keys: %v", s.keys)
+	}
+	// 被淘汰的key重新预留, 区间中未发出的值被跳过, 仍然递增
+	v, err := s.Next(ctx, "m2")
+	if err != nil || v != 11 {
+		t.Fatalf("m2 after eviction: %d %v", v, err)
+	}
+	// m3 仍在缓存中, 不需要预留
+	if v, _ = s.Next(ctx, "m3"); v != 2 {
+		t.Fatalf("m3: %d", v)
+	}
+	if store.reserves != 4 {
+		t.Fatalf("reserves: %d", store.reserves)
+	}
+}
+
+func TestKeyedSequence_restart(t *testing.T) {
+	store := NewMemStore()
+	ctx := context.Background()
+	s := New(store, WithBatch(100))
+	last, _ := s.Next(ctx, "invoice")
+	// 模拟崩溃: 新实例不会重复发出已预留区间中的值
+	s2 := New(store, WithBatch(100))
+	v, err := s2.Next(ctx, "invoice")
+	if err != nil || v <= last || v != 101 {
+		t.Fatalf("after restart: %d %v", v, err)
+	}
+}
+
+func TestKeyedSequence_errors(t *testing.T) {
+	store := &countingStore{MemStore: NewMemStore()}
+	s := New(store, WithBatch(5))
+	ctx := context.Background()
+	s.NextN(ctx, "m1", 3)
+
+	store.err = errors.New("db down")
+	if _, err := s.NextN(ctx, "m1", 4); err == nil {
+		t.Fatal("NextN should fail with the store down")
+	}
+	// 失败时当前区间的值不会丢失
+	if vals, err := s.NextN(ctx, "m1", 2); err != nil || vals[0] != 4 || vals[1] != 5 {
+		t.Fatalf("NextN from the current block: %v %v", vals, err)
+	}
+	store.err = nil
+	if v, err := s.Next(ctx, "m1"); err != nil || v != 6 {
+		t.Fatalf("Next after the store recovered: %d %v", v, err)
+	}
+
+	s.Close()
+	if _, err := s.Next(ctx, "m1"); !errors.Is(err, common.WorkerClosedErr) {
+		t.Fatalf("Next after Close: %v", err)
+	}
+}
+
+func TestKeyedSequence_concurrent(t *testing.T) {
+	store := NewMemStore()
+	// 两个实例共用存储, 模拟两个进程
+	seqs := []*KeyedSequence{New(store, WithBatch(7)), New(store, WithBatch(7))}
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = map[int64]bool{}
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(s *KeyedSequence) {
+			defer wg.Done()
+			var last int64
+			for j := 0; j < 200; j++ {
+				v, err := s.Next(context.Background(), "m1")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if v <= last {
+					t.Errorf("not increasing: %d after %d", v, last)
+				}
+				last = v
+				mu.Lock()
+				if seen[v] {
+					t.Errorf("duplicate value %d", v)
+				}
+				seen[v] = true
+				mu.Unlock()
+			}
+		}(seqs[i%2])
+	}
+	wg.Wait()
+}
@@ -0,0 +1,180 @@
+package sequence
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/lypee/snowFlake/common"
+	"github.com/lypee/snowFlake/server/zkServer"
+)
+
+// Store 按key保存已分配的计数上界
+type Store interface {
+	// Reserve 把 key 的上界原子地增加 n 并在持久化后返回增加后的值, 即调用方独占 (max-n, max]; key 不存在时从0开始
+	Reserve(ctx context.Context, key string, n int64) (max int64, err error)
+}
+
+// MemStore 进程内的存储, 重启后从头计数, 只用于测试或单进程且不要求重启后不重复的场景
+type MemStore struct {
+	mu  sync.Mutex
+	max map[string]int64
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{max: map[string]int64{}}
+}
+
+func (s *MemStore) Reserve(_ context.Context, key string, n int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.max[key] += n
+	return s.max[key], nil
+}
+
+// Schema MySQL 的建表语句, 其他数据库按需调整类型
+const Schema = `CREATE TABLE IF NOT EXISTS keyed_seq (
+  seq_key     VARCHAR(191) NOT NULL,
+  max_val     BIGINT       NOT NULL,
+  update_time TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (seq_key)
+)`
+
+type sqlOpt struct {
+	table       string
+	dollar      bool             // 占位符使用 $1, $2 (PostgreSQL), 默认为 ?
+	isDuplicate func(error) bool // INSERT 的错误是否为主键冲突
+}
+
+type SQLOptFunc func(opt *sqlOpt)
+
+// WithTable 表名, 默认 keyed_seq
+func WithTable(name string) SQLOptFunc {
+	return func(opt *sqlOpt) {
+		opt.table = name
+	}
+}
+
+// WithDollarPlaceholders 使用 PostgreSQL 风格的占位符
+func WithDollarPlaceholders() SQLOptFunc {
+	return func(opt *sqlOpt) {
+		opt.dollar = true
+	}
+}
+
+// WithDuplicateKeyFunc 判断 INSERT 的错误是否为主键冲突, 默认识别 MySQL、PostgreSQL 和 SQLite 的错误,
+// 其他驱动可以按错误码指定
+func WithDuplicateKeyFunc(f func(error) bool) SQLOptFunc {
+	return func(opt *sqlOpt) {
+		opt.isDuplicate = f
+	}
+}
+
+// isDuplicateKey 按 SQLSTATE 23505 或错误信息判断主键冲突, 不依赖具体的驱动
+func isDuplicateKey(err error) bool {
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) && state.SQLState() == "23505" {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"duplicate entry", "duplicate key", "unique constraint"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// SQLStore 基于 database/sql 的存储, 在一个事务中 UPDATE 后读回, key 不存在时 INSERT
+type SQLStore struct {
+	db          *sql.DB
+	update      string
+	insert      string
+	query       string
+	isDuplicate func(error) bool
+}
+
+func NewSQLStore(db *sql.DB, ofs ...SQLOptFunc) *SQLStore {
+	opt := &sqlOpt{table: "keyed_seq", isDuplicate: isDuplicateKey}
+	for _, of := range ofs {
+		of(opt)
+	}
+	p := func(i int) string {
+		if opt.dollar {
+			return fmt.Sprintf("$%d", i)
+		}
+		return "?"
+	}
+	return &SQLStore{
+		db:          db,
+		update:      fmt.Sprintf("UPDATE %s SET max_val = max_val + %s WHERE seq_key = %s", opt.table, p(1), p(2)),
+		insert:      fmt.Sprintf("INSERT INTO %s (seq_key, max_val) VALUES (%s, %s)", opt.table, p(1), p(2)),
+		query:       fmt.Sprintf("SELECT max_val FROM %s WHERE seq_key = %s", opt.table, p(1)),
+		isDuplicate: opt.isDuplicate,
+	}
+}
+
+// errInsertRace INSERT 主键冲突, 其他进程同时创建了该 key
+var errInsertRace = errors.New("key inserted concurrently")
+
+func (s *SQLStore) Reserve(ctx context.Context, key string, n int64) (int64, error) {
+	max, err := s.reserve(ctx, key, n)
+	if errors.Is(err, errInsertRace) {
+		// 行已被其他进程创建时, 重试一次即可走 UPDATE
+		max, err = s.reserve(ctx, key, n)
+	}
+	return max, err
+}
+
+func (s *SQLStore) reserve(ctx context.Context, key string, n int64) (max int64, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, common.OpErr.WithTrueErr(err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, s.update, n, key)
+	if err != nil {
+		return 0, common.OpErr.WithTrueErr(err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, common.OpErr.WithTrueErr(err)
+	}
+	if rows == 0 {
+		if _, err = tx.ExecContext(ctx, s.insert, key, n); err != nil {
+			if s.isDuplicate(err) {
+				return 0, common.OpErr.WithTrueErr(fmt.Errorf("%w: %v", errInsertRace, err))
+			}
+			return 0, common.OpErr.WithTrueErr(err)
+		}
+		max = n
+	} else if err = tx.QueryRowContext(ctx, s.query, key).Scan(&max); err != nil {
+		return 0, common.OpErr.WithTrueErr(err)
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, common.OpErr.WithTrueErr(err)
+	}
+	return max, nil
+}
+
+// ZkStore 基于 zk 计数节点的存储, 每个key一个持久节点 <appRootPath>/_seq/<key>, 用节点版本做 compare-and-set;
+// 复用 ZkServer 的连接和命名空间
+type ZkStore struct {
+	srv *zkServer.ZkServer
+}
+
+func NewZkStore(srv *zkServer.ZkServer) *ZkStore {
+	return &ZkStore{srv: srv}
+}
+
+func (s *ZkStore) Reserve(ctx context.Context, key string, n int64) (int64, error) {
+	return s.srv.AddCounter(ctx, key, n)
+}
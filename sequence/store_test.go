@@ -0,0 +1,118 @@
+package sequence
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+func TestSQLStore_Reserve(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store := NewSQLStore(db)
+	ctx := context.Background()
+
+	// key 不存在时创建
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE keyed_seq SET max_val = max_val + ? WHERE seq_key = ?")).
+		WithArgs(100, "m1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO keyed_seq (seq_key, max_val) VALUES (?, ?)")).
+		WithArgs("m1", 100).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if max, err := store.Reserve(ctx, "m1", 100); err != nil || max != 100 {
+		t.Fatalf("Reserve new key: %d %v", max, err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE keyed_seq").WithArgs(100, "m1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT max_val FROM keyed_seq WHERE seq_key = ?")).
+		WithArgs("m1").WillReturnRows(sqlmock.NewRows([]string{"max_val"}).AddRow(200))
+	mock.ExpectCommit()
+	if max, err := store.Reserve(ctx, "m1", 100); err != nil || max != 200 {
+		t.Fatalf("Reserve: %d %v", max, err)
+	}
+
+	// 其他进程同时创建了该 key: INSERT 失败后重试走 UPDATE
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE keyed_seq").WithArgs(10, "m2").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO keyed_seq").WithArgs("m2", 10).WillReturnError(errors.New("duplicate entry"))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE keyed_seq").WithArgs(10, "m2").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT max_val").WithArgs("m2").WillReturnRows(sqlmock.NewRows([]string{"max_val"}).AddRow(20))
+	mock.ExpectCommit()
+	if max, err := store.Reserve(ctx, "m2", 10); err != nil || max != 20 {
+		t.Fatalf("Reserve after an insert race: %d %v", max, err)
+	}
+
+	// 其他 INSERT 错误不重试, 原样返回
+	connErr := errors.New("connection reset by peer")
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE keyed_seq").WithArgs(10, "m3").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO keyed_seq").WithArgs("m3", 10).WillReturnError(connErr)
+	mock.ExpectRollback()
+	if _, err = store.Reserve(ctx, "m3", 10); !errors.Is(err, connErr) || errors.Is(err, errInsertRace) {
+		t.Fatalf("insert error: %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE keyed_seq").WithArgs(10, "m1").WillReturnError(errors.New("deadlock"))
+	mock.ExpectRollback()
+	if _, err = store.Reserve(ctx, "m1", 10); !errors.Is(err, common.OpErr) {
+		t.Fatalf("exec error: %v", err)
+	}
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pgError 模拟 PostgreSQL 驱动的错误
+type pgError struct{ code string }
+
+func (e *pgError) Error() string    { return "pq: error " + e.code }
+func (e *pgError) SQLState() string { return e.code }
+
+func TestIsDuplicateKey(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("Error 1062 (23000): Duplicate entry 'm1' for key 'PRIMARY'"), true},
+		{errors.New("UNIQUE constraint failed: keyed_seq.seq_key"), true},
+		{&pgError{code: "23505"}, true},
+		{&pgError{code: "23502"}, false},
+		{errors.New("Error 1452 (23000): Cannot add or update a child row: a foreign key constraint fails"), false},
+		{errors.New("driver: bad connection"), false},
+	} {
+		if got := isDuplicateKey(c.err); got != c.want {
+			t.Errorf("isDuplicateKey(%v) = %v", c.err, got)
+		}
+	}
+}
+
+func TestSQLStore_placeholders(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store := NewSQLStore(db, WithTable("seq"), WithDollarPlaceholders())
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE seq SET max_val = max_val + $1 WHERE seq_key = $2")).
+		WithArgs(5, "m1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO seq (seq_key, max_val) VALUES ($1, $2)")).
+		WithArgs("m1", 5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if _, err = store.Reserve(context.Background(), "m1", 5); err != nil {
+		t.Fatal(err)
+	}
+}
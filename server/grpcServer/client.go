@@ -0,0 +1,68 @@
+package grpcServer
+
+import (
+	"context"
+	"io"
+
+	"google.golang.org/grpc"
+
+	"github.com/lypee/snowFlake/server/grpcServer/pb"
+)
+
+// Client IdService 的客户端
+type Client struct {
+	conn *grpc.ClientConn
+	c    pb.IdServiceClient
+}
+
+// Dial 连接 target, 例如 grpc.WithTransportCredentials(insecure.NewCredentials())
+func Dial(target string, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, c: pb.NewIdServiceClient(conn)}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) NextID(ctx context.Context) (uint64, error) {
+	resp, err := c.c.NextID(ctx, &pb.NextIDRequest{})
+	if err != nil {
+		return 0, err
+	}
+	return resp.Id, nil
+}
+
+func (c *Client) NextIDs(ctx context.Context, count int) ([]uint64, error) {
+	resp, err := c.c.NextIDs(ctx, &pb.NextIDsRequest{Count: uint32(count)})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Ids, nil
+}
+
+// StreamIDs 持续接收ID并交给 fn, count 为0时直到 ctx 取消或 fn 返回错误
+// fn 处理得慢时服务端会因流控而放慢发送
+func (c *Client) StreamIDs(ctx context.Context, count uint64, chunkSize uint32, fn func(ids []uint64) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.c.StreamIDs(ctx, &pb.StreamIDsRequest{Count: count, ChunkSize: chunkSize})
+	if err != nil {
+		return err
+	}
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = fn(chunk.Ids); err != nil {
+			return err
+		}
+	}
+}
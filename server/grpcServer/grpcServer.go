@@ -0,0 +1,103 @@
+//go:generate buf generate
+
+package grpcServer
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/lypee/snowFlake/common"
+	"github.com/lypee/snowFlake/server/grpcServer/pb"
+)
+
+const (
+	// MaxBatch NextIDs 单次和 StreamIDs 每批最多的ID个数
+	MaxBatch = 10000
+	// DefaultChunkSize StreamIDs 未指定 chunk_size 时每批的个数
+	DefaultChunkSize = 256
+)
+
+// Generator gRPC 服务依赖的发号器, *snowFlake.SfWorker 实现了该接口
+type Generator interface {
+	NextID() (uint64, error)
+	NextIDs(n int) ([]uint64, error)
+}
+
+// Server 实现 pb.IdServiceServer
+type Server struct {
+	pb.UnimplementedIdServiceServer
+	gen Generator
+}
+
+func New(gen Generator) *Server {
+	return &Server{gen: gen}
+}
+
+// Register 把服务注册到 s
+func (srv *Server) Register(s *grpc.Server) {
+	pb.RegisterIdServiceServer(s, srv)
+}
+
+func (srv *Server) NextID(_ context.Context, _ *pb.NextIDRequest) (*pb.NextIDResponse, error) {
+	id, err := srv.gen.NextID()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.NextIDResponse{Id: id}, nil
+}
+
+func (srv *Server) NextIDs(_ context.Context, req *pb.NextIDsRequest) (*pb.NextIDsResponse, error) {
+	if req.Count < 1 || req.Count > MaxBatch {
+		return nil, status.Errorf(codes.InvalidArgument, "count must be in [1, %d]", MaxBatch)
+	}
+	ids, err := srv.gen.NextIDs(int(req.Count))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.NextIDsResponse{Ids: ids}, nil
+}
+
+// StreamIDs 分批发送, Send 在客户端窗口用完时阻塞, 生成速度因此受客户端消费速度约束
+func (srv *Server) StreamIDs(req *pb.StreamIDsRequest, stream pb.IdService_StreamIDsServer) error {
+	chunk := uint64(req.ChunkSize)
+	if chunk == 0 {
+		chunk = DefaultChunkSize
+	}
+	if chunk > MaxBatch {
+		return status.Errorf(codes.InvalidArgument, "chunk_size must be in [1, %d]", MaxBatch)
+	}
+	ctx := stream.Context()
+	for sent := uint64(0); req.Count == 0 || sent < req.Count; {
+		if err := ctx.Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		n := chunk
+		if req.Count > 0 && req.Count-sent < n {
+			n = req.Count - sent
+		}
+		ids, err := srv.gen.NextIDs(int(n))
+		if err != nil {
+			return toStatus(err)
+		}
+		if err = stream.Send(&pb.IDChunk{Ids: ids}); err != nil {
+			return err
+		}
+		sent += n
+	}
+	return nil
+}
+
+// toStatus 租约丢失、已关闭、时钟回拨等暂时不可用的情况返回 Unavailable, 其余返回 Internal
+// 错误信息中带有 common.Err 的错误码
+func toStatus(err error) error {
+	code := codes.Internal
+	if errors.Is(err, common.LeaseConflictErr) || errors.Is(err, common.WorkerClosedErr) ||
+		errors.Is(err, common.ClockBackwardsErr) || errors.Is(err, common.SequenceExhaustedErr) {
+		code = codes.Unavailable
+	}
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,106 @@
+package grpcServer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	snowFlake "github.com/lypee/snowFlake"
+)
+
+func newBufClient(t *testing.T) (*snowFlake.SfWorker, *Client) {
+	t.Helper()
+	sf, err := snowFlake.NewWorker(snowFlake.WithDataCenterId(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	New(sf).Register(s)
+	go s.Serve(lis)
+
+	client, err := Dial("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		s.Stop()
+		sf.Close(context.Background())
+	})
+	return sf, client
+}
+
+func TestServer_unary(t *testing.T) {
+	_, client := newBufClient(t)
+	ctx := context.Background()
+	id, err := client.NextID(ctx)
+	if err != nil || id == 0 {
+		t.Fatalf("NextID: %d %v", id, err)
+	}
+	ids, err := client.NextIDs(ctx, 100)
+	if err != nil || len(ids) != 100 {
+		t.Fatalf("NextIDs: %d %v", len(ids), err)
+	}
+	if ids[0] <= id {
+		t.Fatalf("ids not increasing: %d %d", id, ids[0])
+	}
+	if _, err = client.NextIDs(ctx, 0); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("count 0: %v", err)
+	}
+}
+
+func TestServer_StreamIDs(t *testing.T) {
+	_, client := newBufClient(t)
+	seen := make(map[uint64]bool)
+	chunks := 0
+	err := client.StreamIDs(context.Background(), 1000, 64, func(ids []uint64) error {
+		chunks++
+		for _, id := range ids {
+			if seen[id] {
+				t.Fatalf("duplicate id %d", id)
+			}
+			seen[id] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 1000 || chunks != 16 {
+		t.Fatalf("got %d ids in %d chunks", len(seen), chunks)
+	}
+
+	// count 为0时持续推送, 直到客户端停止
+	stop := errors.New("stop")
+	total := 0
+	err = client.StreamIDs(context.Background(), 0, 0, func(ids []uint64) error {
+		total += len(ids)
+		if total >= 10*DefaultChunkSize {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Fatalf("expected stop, got %v", err)
+	}
+}
+
+func TestServer_closedWorker(t *testing.T) {
+	sf, client := newBufClient(t)
+	sf.Close(context.Background())
+	if _, err := client.NextID(context.Background()); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable, got %v", err)
+	}
+}
@@ -0,0 +1,478 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: pb/snowflake.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type NextIDRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *NextIDRequest) Reset() {
+	*x = NextIDRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pb_snowflake_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NextIDRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NextIDRequest) ProtoMessage() {}
+
+func (x *NextIDRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_snowflake_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NextIDRequest.ProtoReflect.Descriptor instead.
+func (*NextIDRequest) Descriptor() ([]byte, []int) {
+	return file_pb_snowflake_proto_rawDescGZIP(), []int{0}
+}
+
+type NextIDResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *NextIDResponse) Reset() {
+	*x = NextIDResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pb_snowflake_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NextIDResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NextIDResponse) ProtoMessage() {}
+
+func (x *NextIDResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_snowflake_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NextIDResponse.ProtoReflect.Descriptor instead.
+func (*NextIDResponse) Descriptor() ([]byte, []int) {
+	return file_pb_snowflake_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *NextIDResponse) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type NextIDsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Count uint32 `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+}
+
+func (x *NextIDsRequest) Reset() {
+	*x = NextIDsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pb_snowflake_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NextIDsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NextIDsRequest) ProtoMessage() {}
+
+func (x *NextIDsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_snowflake_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NextIDsRequest.ProtoReflect.Descriptor instead.
+func (*NextIDsRequest) Descriptor() ([]byte, []int) {
+	return file_pb_snowflake_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *NextIDsRequest) GetCount() uint32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type NextIDsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Ids []uint64 `protobuf:"varint,1,rep,packed,name=ids,proto3" json:"ids,omitempty"`
+}
+
+func (x *NextIDsResponse) Reset() {
+	*x = NextIDsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pb_snowflake_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NextIDsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NextIDsResponse) ProtoMessage() {}
+
+func (x *NextIDsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_snowflake_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NextIDsResponse.ProtoReflect.Descriptor instead.
+func (*NextIDsResponse) Descriptor() ([]byte, []int) {
+	return file_pb_snowflake_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *NextIDsResponse) GetIds() []uint64 {
+	if x != nil {
+		return x.Ids
+	}
+	return nil
+}
+
+type StreamIDsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 总数, 0 表示直到客户端取消
+	Count uint64 `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	// 每批的个数, 0 表示使用服务端默认值
+	ChunkSize uint32 `protobuf:"varint,2,opt,name=chunk_size,json=chunkSize,proto3" json:"chunk_size,omitempty"`
+}
+
+func (x *StreamIDsRequest) Reset() {
+	*x = StreamIDsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pb_snowflake_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StreamIDsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamIDsRequest) ProtoMessage() {}
+
+func (x *StreamIDsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_snowflake_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamIDsRequest.ProtoReflect.Descriptor instead.
+func (*StreamIDsRequest) Descriptor() ([]byte, []int) {
+	return file_pb_snowflake_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *StreamIDsRequest) GetCount() uint64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *StreamIDsRequest) GetChunkSize() uint32 {
+	if x != nil {
+		return x.ChunkSize
+	}
+	return 0
+}
+
+type IDChunk struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Ids []uint64 `protobuf:"varint,1,rep,packed,name=ids,proto3" json:"ids,omitempty"`
+}
+
+func (x *IDChunk) Reset() {
+	*x = IDChunk{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pb_snowflake_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *IDChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IDChunk) ProtoMessage() {}
+
+func (x *IDChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_pb_snowflake_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IDChunk.ProtoReflect.Descriptor instead.
+func (*IDChunk) Descriptor() ([]byte, []int) {
+	return file_pb_snowflake_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *IDChunk) GetIds() []uint64 {
+	if x != nil {
+		return x.Ids
+	}
+	return nil
+}
+
+var File_pb_snowflake_proto protoreflect.FileDescriptor
+
+var file_pb_snowflake_proto_rawDesc = []byte{
+	0x0a, 0x12, 0x70, 0x62, 0x2f, 0x73, 0x6e, 0x6f, 0x77, 0x66, 0x6c, 0x61, 0x6b, 0x65, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0c, 0x73, 0x6e, 0x6f, 0x77, 0x66, 0x6c, 0x61, 0x6b, 0x65, 0x2e,
+	0x76, 0x31, 0x22, 0x0f, 0x0a, 0x0d, 0x4e, 0x65, 0x78, 0x74, 0x49, 0x44, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x22, 0x20, 0x0a, 0x0e, 0x4e, 0x65, 0x78, 0x74, 0x49, 0x44, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x02, 0x69, 0x64, 0x22, 0x26, 0x0a, 0x0e, 0x4e, 0x65, 0x78, 0x74, 0x49, 0x44, 0x73,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x22, 0x23, 0x0a,
+	0x0f, 0x4e, 0x65, 0x78, 0x74, 0x49, 0x44, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x10, 0x0a, 0x03, 0x69, 0x64, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x04, 0x52, 0x03, 0x69,
+	0x64, 0x73, 0x22, 0x47, 0x0a, 0x10, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x44, 0x73, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x1d, 0x0a, 0x0a,
+	0x63, 0x68, 0x75, 0x6e, 0x6b, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x09, 0x63, 0x68, 0x75, 0x6e, 0x6b, 0x53, 0x69, 0x7a, 0x65, 0x22, 0x1b, 0x0a, 0x07, 0x49,
+	0x44, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x12, 0x10, 0x0a, 0x03, 0x69, 0x64, 0x73, 0x18, 0x01, 0x20,
+	0x03, 0x28, 0x04, 0x52, 0x03, 0x69, 0x64, 0x73, 0x32, 0xde, 0x01, 0x0a, 0x09, 0x49, 0x64, 0x53,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x43, 0x0a, 0x06, 0x4e, 0x65, 0x78, 0x74, 0x49, 0x44,
+	0x12, 0x1b, 0x2e, 0x73, 0x6e, 0x6f, 0x77, 0x66, 0x6c, 0x61, 0x6b, 0x65, 0x2e, 0x76, 0x31, 0x2e,
+	0x4e, 0x65, 0x78, 0x74, 0x49, 0x44, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1c, 0x2e,
+	0x73, 0x6e, 0x6f, 0x77, 0x66, 0x6c, 0x61, 0x6b, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x4e, 0x65, 0x78,
+	0x74, 0x49, 0x44, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x46, 0x0a, 0x07, 0x4e,
+	0x65, 0x78, 0x74, 0x49, 0x44, 0x73, 0x12, 0x1c, 0x2e, 0x73, 0x6e, 0x6f, 0x77, 0x66, 0x6c, 0x61,
+	0x6b, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x4e, 0x65, 0x78, 0x74, 0x49, 0x44, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x1d, 0x2e, 0x73, 0x6e, 0x6f, 0x77, 0x66, 0x6c, 0x61, 0x6b, 0x65,
+	0x2e, 0x76, 0x31, 0x2e, 0x4e, 0x65, 0x78, 0x74, 0x49, 0x44, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x44, 0x0a, 0x09, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x44, 0x73,
+	0x12, 0x1e, 0x2e, 0x73, 0x6e, 0x6f, 0x77, 0x66, 0x6c, 0x61, 0x6b, 0x65, 0x2e, 0x76, 0x31, 0x2e,
+	0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x44, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x15, 0x2e, 0x73, 0x6e, 0x6f, 0x77, 0x66, 0x6c, 0x61, 0x6b, 0x65, 0x2e, 0x76, 0x31, 0x2e,
+	0x49, 0x44, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x30, 0x01, 0x42, 0x34, 0x5a, 0x32, 0x67, 0x69, 0x74,
+	0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6c, 0x79, 0x70, 0x65, 0x65, 0x2f, 0x73, 0x6e,
+	0x6f, 0x77, 0x46, 0x6c, 0x61, 0x6b, 0x65, 0x2f, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2f, 0x67,
+	0x72, 0x70, 0x63, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2f, 0x70, 0x62, 0x3b, 0x70, 0x62, 0x62,
+	0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_pb_snowflake_proto_rawDescOnce sync.Once
+	file_pb_snowflake_proto_rawDescData = file_pb_snowflake_proto_rawDesc
+)
+
+func file_pb_snowflake_proto_rawDescGZIP() []byte {
+	file_pb_snowflake_proto_rawDescOnce.Do(func() {
+		file_pb_snowflake_proto_rawDescData = protoimpl.X.CompressGZIP(file_pb_snowflake_proto_rawDescData)
+	})
+	return file_pb_snowflake_proto_rawDescData
+}
+
+var file_pb_snowflake_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_pb_snowflake_proto_goTypes = []any{
+	(*NextIDRequest)(nil),    // 0: snowflake.v1.NextIDRequest
+	(*NextIDResponse)(nil),   // 1: snowflake.v1.NextIDResponse
+	(*NextIDsRequest)(nil),   // 2: snowflake.v1.NextIDsRequest
+	(*NextIDsResponse)(nil),  // 3: snowflake.v1.NextIDsResponse
+	(*StreamIDsRequest)(nil), // 4: snowflake.v1.StreamIDsRequest
+	(*IDChunk)(nil),          // 5: snowflake.v1.IDChunk
+}
+var file_pb_snowflake_proto_depIdxs = []int32{
+	0, // 0: snowflake.v1.IdService.NextID:input_type -> snowflake.v1.NextIDRequest
+	2, // 1: snowflake.v1.IdService.NextIDs:input_type -> snowflake.v1.NextIDsRequest
+	4, // 2: snowflake.v1.IdService.StreamIDs:input_type -> snowflake.v1.StreamIDsRequest
+	1, // 3: snowflake.v1.IdService.NextID:output_type -> snowflake.v1.NextIDResponse
+	3, // 4: snowflake.v1.IdService.NextIDs:output_type -> snowflake.v1.NextIDsResponse
+	5, // 5: snowflake.v1.IdService.StreamIDs:output_type -> snowflake.v1.IDChunk
+	3, // [3:6] is the sub-list for method output_type
+	0, // [0:3] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_pb_snowflake_proto_init() }
+func file_pb_snowflake_proto_init() {
+	if File_pb_snowflake_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_pb_snowflake_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*NextIDRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pb_snowflake_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*NextIDResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pb_snowflake_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*NextIDsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pb_snowflake_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*NextIDsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pb_snowflake_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*StreamIDsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pb_snowflake_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*IDChunk); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_pb_snowflake_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pb_snowflake_proto_goTypes,
+		DependencyIndexes: file_pb_snowflake_proto_depIdxs,
+		MessageInfos:      file_pb_snowflake_proto_msgTypes,
+	}.Build()
+	File_pb_snowflake_proto = out.File
+	file_pb_snowflake_proto_rawDesc = nil
+	file_pb_snowflake_proto_goTypes = nil
+	file_pb_snowflake_proto_depIdxs = nil
+}
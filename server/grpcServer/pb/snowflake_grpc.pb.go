@@ -0,0 +1,224 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: pb/snowflake.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	IdService_NextID_FullMethodName    = "/snowflake.v1.IdService/NextID"
+	IdService_NextIDs_FullMethodName   = "/snowflake.v1.IdService/NextIDs"
+	IdService_StreamIDs_FullMethodName = "/snowflake.v1.IdService/StreamIDs"
+)
+
+// IdServiceClient is the client API for IdService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// IdService 发号服务
+type IdServiceClient interface {
+	// NextID 生成一个ID
+	NextID(ctx context.Context, in *NextIDRequest, opts ...grpc.CallOption) (*NextIDResponse, error)
+	// NextIDs 一次生成 count 个ID
+	NextIDs(ctx context.Context, in *NextIDsRequest, opts ...grpc.CallOption) (*NextIDsResponse, error)
+	// StreamIDs 按 chunk_size 分批推送ID, 客户端消费不及时时受流控阻塞
+	StreamIDs(ctx context.Context, in *StreamIDsRequest, opts ...grpc.CallOption) (IdService_StreamIDsClient, error)
+}
+
+type idServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewIdServiceClient(cc grpc.ClientConnInterface) IdServiceClient {
+	return &idServiceClient{cc}
+}
+
+func (c *idServiceClient) NextID(ctx context.Context, in *NextIDRequest, opts ...grpc.CallOption) (*NextIDResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NextIDResponse)
+	err := c.cc.Invoke(ctx, IdService_NextID_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *idServiceClient) NextIDs(ctx context.Context, in *NextIDsRequest, opts ...grpc.CallOption) (*NextIDsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NextIDsResponse)
+	err := c.cc.Invoke(ctx, IdService_NextIDs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *idServiceClient) StreamIDs(ctx context.Context, in *StreamIDsRequest, opts ...grpc.CallOption) (IdService_StreamIDsClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &IdService_ServiceDesc.Streams[0], IdService_StreamIDs_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &idServiceStreamIDsClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type IdService_StreamIDsClient interface {
+	Recv() (*IDChunk, error)
+	grpc.ClientStream
+}
+
+type idServiceStreamIDsClient struct {
+	grpc.ClientStream
+}
+
+func (x *idServiceStreamIDsClient) Recv() (*IDChunk, error) {
+	m := new(IDChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// IdServiceServer is the server API for IdService service.
+// All implementations must embed UnimplementedIdServiceServer
+// for forward compatibility
+//
+// IdService 发号服务
+type IdServiceServer interface {
+	// NextID 生成一个ID
+	NextID(context.Context, *NextIDRequest) (*NextIDResponse, error)
+	// NextIDs 一次生成 count 个ID
+	NextIDs(context.Context, *NextIDsRequest) (*NextIDsResponse, error)
+	// StreamIDs 按 chunk_size 分批推送ID, 客户端消费不及时时受流控阻塞
+	StreamIDs(*StreamIDsRequest, IdService_StreamIDsServer) error
+	mustEmbedUnimplementedIdServiceServer()
+}
+
+// UnimplementedIdServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedIdServiceServer struct {
+}
+
+func (UnimplementedIdServiceServer) NextID(context.Context, *NextIDRequest) (*NextIDResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NextID not implemented")
+}
+func (UnimplementedIdServiceServer) NextIDs(context.Context, *NextIDsRequest) (*NextIDsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NextIDs not implemented")
+}
+func (UnimplementedIdServiceServer) StreamIDs(*StreamIDsRequest, IdService_StreamIDsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamIDs not implemented")
+}
+func (UnimplementedIdServiceServer) mustEmbedUnimplementedIdServiceServer() {}
+
+// UnsafeIdServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to IdServiceServer will
+// result in compilation errors.
+type UnsafeIdServiceServer interface {
+	mustEmbedUnimplementedIdServiceServer()
+}
+
+func RegisterIdServiceServer(s grpc.ServiceRegistrar, srv IdServiceServer) {
+	s.RegisterService(&IdService_ServiceDesc, srv)
+}
+
+func _IdService_NextID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NextIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IdServiceServer).NextID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IdService_NextID_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IdServiceServer).NextID(ctx, req.(*NextIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IdService_NextIDs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NextIDsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IdServiceServer).NextIDs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IdService_NextIDs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IdServiceServer).NextIDs(ctx, req.(*NextIDsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IdService_StreamIDs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamIDsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(IdServiceServer).StreamIDs(m, &idServiceStreamIDsServer{ServerStream: stream})
+}
+
+type IdService_StreamIDsServer interface {
+	Send(*IDChunk) error
+	grpc.ServerStream
+}
+
+type idServiceStreamIDsServer struct {
+	grpc.ServerStream
+}
+
+func (x *idServiceStreamIDsServer) Send(m *IDChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// IdService_ServiceDesc is the grpc.ServiceDesc for IdService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var IdService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "snowflake.v1.IdService",
+	HandlerType: (*IdServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "NextID",
+			Handler:    _IdService_NextID_Handler,
+		},
+		{
+			MethodName: "NextIDs",
+			Handler:    _IdService_NextIDs_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamIDs",
+			Handler:       _IdService_StreamIDs_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pb/snowflake.proto",
+}
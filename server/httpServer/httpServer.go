@@ -0,0 +1,301 @@
+package httpServer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	snowFlake "github.com/lypee/snowFlake"
+	"github.com/lypee/snowFlake/base"
+	"github.com/lypee/snowFlake/common"
+	"github.com/lypee/snowFlake/config"
+)
+
+// MaxBatch /ids 单次最多生成的ID个数
+const MaxBatch = 1000
+
+// Generator HTTP 服务依赖的发号器, *snowFlake.SfWorker 实现了该接口
+type Generator interface {
+	NextID() (uint64, error)
+	NextIDs(n int) ([]uint64, error)
+	Decode(id uint64) snowFlake.IDParts
+	Status() snowFlake.WorkerStatus
+}
+
+// Server HTTP 发号服务, ID 以字符串返回, 避免 JavaScript 丢失精度
+//
+//	GET /id             {"id":"..."}
+//	GET /ids?count=N    {"ids":["...", ...]}
+//	GET /decode/{id}    各段拆解结果
+//	GET /healthz        worker 状态, 租约丢失或已关闭时返回503
+//
+// 调用 HandleTags 后还提供按业务标识发号:
+//
+//	GET /id/{tag}            {"id":"..."}
+//	GET /id/{tag}?count=N    {"ids":["...", ...]}
+//	GET /tags                各业务的布局和发号统计
+type Server struct {
+	gen     Generator
+	tags    *snowFlake.Registry
+	srv     *http.Server
+	mux     *http.ServeMux
+	limiter *rateLimiter
+
+	metricsPath string
+	metricsOn   int32
+}
+
+// New 按 Http 和 Metrics 配置创建服务, 监听地址和超时在启动后不可修改
+func New(gen Generator, c *config.Config) *Server {
+	s := &Server{
+		gen:         gen,
+		limiter:     newRateLimiter(c.Http.RateLimit, c.Http.RateBurst),
+		metricsPath: c.Metrics.Path,
+	}
+	s.setMetrics(c.Metrics.Enabled)
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("/id", s.limit(s.handleID))
+	s.mux.HandleFunc("/ids", s.limit(s.handleIDs))
+	s.mux.HandleFunc("/decode/", s.handleDecode)
+	s.mux.HandleFunc("/healthz", s.handleHealth)
+	if s.metricsPath != "" {
+		s.mux.HandleFunc(s.metricsPath, s.handleMetrics)
+	}
+	s.srv = &http.Server{
+		Addr:         c.Http.Listen,
+		Handler:      s.mux,
+		ReadTimeout:  c.Http.ReadTimeout,
+		WriteTimeout: c.Http.WriteTimeout,
+		IdleTimeout:  c.Http.IdleTimeout,
+	}
+	return s
+}
+
+// Apply 应用运行中可以修改的配置: 限流和指标开关
+func (s *Server) Apply(c *config.Config) {
+	s.limiter.set(c.Http.RateLimit, c.Http.RateBurst)
+	s.setMetrics(c.Metrics.Enabled)
+}
+
+func (s *Server) setMetrics(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&s.metricsOn, v)
+}
+
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Handle 在同一端口上挂载其他服务, 例如租约服务; 需在 ListenAndServe 之前调用
+func (s *Server) Handle(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, h)
+}
+
+// HandleTags 挂载 /id/{tag} 和 /tags, 未登记的业务返回404; 需在 ListenAndServe 之前调用
+func (s *Server) HandleTags(r *snowFlake.Registry) {
+	s.tags = r
+	s.mux.HandleFunc("/id/", s.limit(s.handleTagID))
+	s.mux.HandleFunc("/tags", s.handleTags)
+}
+
+// ListenAndServe 阻塞直到 Shutdown, 正常关闭时返回 nil
+func (s *Server) ListenAndServe() error {
+	base.InfoF("http server listen on %s", s.srv.Addr)
+	if err := s.srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Serve 在 l 上提供服务, 正常关闭时返回 nil
+func (s *Server) Serve(l net.Listener) error {
+	if err := s.srv.Serve(l); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Shutdown 停止接收新连接并等待进行中的请求完成
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+func (s *Server) limit(h http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if !s.limiter.allow() {
+			writeJSON(rw, http.StatusTooManyRequests, errBody{Code: http.StatusTooManyRequests, Msg: "rate limited"})
+			return
+		}
+		h(rw, r)
+	}
+}
+
+type errBody struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+func (s *Server) handleID(rw http.ResponseWriter, r *http.Request) {
+	if !allowGet(rw, r) {
+		return
+	}
+	id, err := s.gen.NextID()
+	if err != nil {
+		writeErr(rw, err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, struct {
+		ID uint64 `json:"id,string"`
+	}{id})
+}
+
+func (s *Server) handleIDs(rw http.ResponseWriter, r *http.Request) {
+	if !allowGet(rw, r) {
+		return
+	}
+	count, ok := parseCount(rw, r)
+	if !ok {
+		return
+	}
+	writeIDs(rw, s.gen, count)
+}
+
+func (s *Server) handleTagID(rw http.ResponseWriter, r *http.Request) {
+	if !allowGet(rw, r) {
+		return
+	}
+	tag := strings.TrimPrefix(r.URL.Path, "/id/")
+	g, ok := s.tags.Get(tag)
+	if !ok {
+		writeErr(rw, common.UnknownBizTagErr.WithTrueErr(fmt.Errorf("biz_tag %q", tag)))
+		return
+	}
+	if !r.URL.Query().Has("count") {
+		id, err := g.NextID()
+		if err != nil {
+			writeErr(rw, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, struct {
+			ID uint64 `json:"id,string"`
+		}{id})
+		return
+	}
+	count, ok := parseCount(rw, r)
+	if !ok {
+		return
+	}
+	writeIDs(rw, g, count)
+}
+
+func (s *Server) handleTags(rw http.ResponseWriter, r *http.Request) {
+	if !allowGet(rw, r) {
+		return
+	}
+	writeJSON(rw, http.StatusOK, struct {
+		Tags []snowFlake.TagStats `json:"tags"`
+	}{s.tags.Stats()})
+}
+
+// parseCount 解析 count 参数, 不合法时写入400并返回 false
+func parseCount(rw http.ResponseWriter, r *http.Request) (int, bool) {
+	count, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil || count < 1 || count > MaxBatch {
+		writeJSON(rw, http.StatusBadRequest, errBody{Code: http.StatusBadRequest, Msg: "count must be in [1, " + strconv.Itoa(MaxBatch) + "]"})
+		return 0, false
+	}
+	return count, true
+}
+
+func writeIDs(rw http.ResponseWriter, gen snowFlake.Generator, count int) {
+	ids, err := gen.NextIDs(count)
+	if err != nil {
+		writeErr(rw, err)
+		return
+	}
+	res := make([]string, len(ids))
+	for i, id := range ids {
+		res[i] = strconv.FormatUint(id, 10)
+	}
+	writeJSON(rw, http.StatusOK, struct {
+		IDs []string `json:"ids"`
+	}{res})
+}
+
+func (s *Server) handleDecode(rw http.ResponseWriter, r *http.Request) {
+	if !allowGet(rw, r) {
+		return
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/decode/"), 10, 64)
+	if err != nil {
+		writeJSON(rw, http.StatusBadRequest, errBody{Code: http.StatusBadRequest, Msg: "invalid id"})
+		return
+	}
+	p := s.gen.Decode(id)
+	writeJSON(rw, http.StatusOK, struct {
+		snowFlake.IDParts
+		Time string `json:"time"`
+	}{p, p.Time().UTC().Format(time.RFC3339Nano)})
+}
+
+func (s *Server) handleHealth(rw http.ResponseWriter, r *http.Request) {
+	st := s.gen.Status()
+	code := http.StatusOK
+	if st.LeaseLost || st.Closed {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(rw, code, st)
+}
+
+func (s *Server) handleMetrics(rw http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&s.metricsOn) == 0 {
+		http.NotFound(rw, r)
+		return
+	}
+	expvar.Handler().ServeHTTP(rw, r)
+}
+
+func allowGet(rw http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet {
+		return true
+	}
+	rw.Header().Set("Allow", http.MethodGet)
+	writeJSON(rw, http.StatusMethodNotAllowed, errBody{Code: http.StatusMethodNotAllowed, Msg: "method not allowed"})
+	return false
+}
+
+// writeErr 发号失败: 租约丢失、已关闭、时钟回拨等暂时不可用的情况返回503, 业务未登记返回404, 其余返回500
+func writeErr(rw http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, common.UnknownBizTagErr) {
+		status = http.StatusNotFound
+	} else if errors.Is(err, common.LeaseConflictErr) || errors.Is(err, common.WorkerClosedErr) ||
+		errors.Is(err, common.ClockBackwardsErr) || errors.Is(err, common.SequenceExhaustedErr) {
+		status = http.StatusServiceUnavailable
+	}
+	body := errBody{Code: common.OpErr.Code, Msg: err.Error()}
+	var e common.Err
+	if errors.As(err, &e) {
+		body.Code = e.Code
+	}
+	writeJSON(rw, status, body)
+}
+
+func writeJSON(rw http.ResponseWriter, status int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		base.WarningF("write response err:[%+v]", err)
+	}
+}
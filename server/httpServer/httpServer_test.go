@@ -0,0 +1,154 @@
+package httpServer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	snowFlake "github.com/lypee/snowFlake"
+	"github.com/lypee/snowFlake/common"
+	"github.com/lypee/snowFlake/config"
+)
+
+func newTestServer(t *testing.T, c *config.Config) (*snowFlake.SfWorker, *httptest.Server, *Server) {
+	t.Helper()
+	sf, err := snowFlake.NewWorker(snowFlake.WithDataCenterId(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := New(sf, c)
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(func() {
+		ts.Close()
+		sf.Close(context.Background())
+	})
+	return sf, ts, srv
+}
+
+func get(t *testing.T, url string, v interface{}) int {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestServer_ids(t *testing.T) {
+	_, ts, _ := newTestServer(t, &config.Config{})
+
+	var one struct{ ID string }
+	if code := get(t, ts.URL+"/id", &one); code != http.StatusOK || one.ID == "" {
+		t.Fatalf("/id: %d %+v", code, one)
+	}
+	var batch struct{ IDs []string }
+	if code := get(t, ts.URL+"/ids?count=5", &batch); code != http.StatusOK || len(batch.IDs) != 5 {
+		t.Fatalf("/ids: %d %+v", code, batch)
+	}
+	if code := get(t, ts.URL+"/ids?count=0", nil); code != http.StatusBadRequest {
+		t.Fatalf("/ids?count=0: %d", code)
+	}
+
+	var parts struct {
+		ID           string
+		DataCenterID int64 `json:"dataCenterId"`
+		WorkerID     int64 `json:"workerId"`
+		Time         string
+	}
+	if code := get(t, ts.URL+"/decode/"+one.ID, &parts); code != http.StatusOK || parts.ID != one.ID || parts.DataCenterID != 2 || parts.Time == "" {
+		t.Fatalf("/decode: %d %+v", code, parts)
+	}
+	if code := get(t, ts.URL+"/decode/abc", nil); code != http.StatusBadRequest {
+		t.Fatalf("/decode/abc: %d", code)
+	}
+	if _, err := strconv.ParseUint(one.ID, 10, 64); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer_tags(t *testing.T) {
+	sf, ts, srv := newTestServer(t, &config.Config{})
+	reg := snowFlake.NewRegistry(sf)
+	users, err := reg.Register("users", snowFlake.Layout{SequenceBits: 6, ReservedBits: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.HandleTags(reg)
+
+	var one struct{ ID string }
+	if code := get(t, ts.URL+"/id/users", &one); code != http.StatusOK || one.ID == "" {
+		t.Fatalf("/id/users: %d %+v", code, one)
+	}
+	id, _ := strconv.ParseUint(one.ID, 10, 64)
+	if p := users.Decode(id); p.DataCenterID != 2 || id&0xf != 0 {
+		t.Fatalf("/id/users parts: %+v", p)
+	}
+	var batch struct{ IDs []string }
+	if code := get(t, ts.URL+"/id/users?count=3", &batch); code != http.StatusOK || len(batch.IDs) != 3 {
+		t.Fatalf("/id/users?count=3: %d %+v", code, batch)
+	}
+	if code := get(t, ts.URL+"/id/users?count=0", nil); code != http.StatusBadRequest {
+		t.Fatalf("/id/users?count=0: %d", code)
+	}
+	var e errBody
+	if code := get(t, ts.URL+"/id/orders", &e); code != http.StatusNotFound || e.Code != common.UnknownBizTagErr.Code {
+		t.Fatalf("/id/orders: %d %+v", code, e)
+	}
+
+	var stats struct{ Tags []snowFlake.TagStats }
+	if code := get(t, ts.URL+"/tags", &stats); code != http.StatusOK || len(stats.Tags) != 1 || stats.Tags[0].Issued != 4 {
+		t.Fatalf("/tags: %d %+v", code, stats)
+	}
+	// /id 不受影响
+	if code := get(t, ts.URL+"/id", &one); code != http.StatusOK {
+		t.Fatalf("/id: %d", code)
+	}
+}
+
+func TestServer_health(t *testing.T) {
+	sf, ts, _ := newTestServer(t, &config.Config{})
+	if code := get(t, ts.URL+"/healthz", nil); code != http.StatusOK {
+		t.Fatalf("healthz: %d", code)
+	}
+	sf.Close(context.Background())
+	if code := get(t, ts.URL+"/healthz", nil); code != http.StatusServiceUnavailable {
+		t.Fatalf("healthz after close: %d", code)
+	}
+	if code := get(t, ts.URL+"/id", nil); code != http.StatusServiceUnavailable {
+		t.Fatalf("/id after close: %d", code)
+	}
+}
+
+func TestServer_Apply(t *testing.T) {
+	c := &config.Config{}
+	c.Http.RateLimit = 1
+	c.Metrics.Path = "/debug/vars"
+	_, ts, srv := newTestServer(t, c)
+
+	get(t, ts.URL+"/id", nil)
+	if code := get(t, ts.URL+"/id", nil); code != http.StatusTooManyRequests {
+		t.Fatalf("expected rate limited, got %d", code)
+	}
+	if code := get(t, ts.URL+"/debug/vars", nil); code != http.StatusNotFound {
+		t.Fatalf("metrics disabled: %d", code)
+	}
+
+	c.Http.RateLimit = 0
+	c.Metrics.Enabled = true
+	srv.Apply(c)
+	if code := get(t, ts.URL+"/id", nil); code != http.StatusOK {
+		t.Fatalf("rate limit not lifted: %d", code)
+	}
+	if code := get(t, ts.URL+"/debug/vars", nil); code != http.StatusOK {
+		t.Fatalf("metrics enabled: %d", code)
+	}
+}
@@ -0,0 +1,115 @@
+package leaseServer
+
+import (
+	"sync"
+
+	"github.com/lypee/snowFlake/common"
+	"github.com/lypee/snowFlake/server/zkServer"
+)
+
+// Backend 租约服务背后的 workerId 分配器
+type Backend interface {
+	Acquire() (workerId int, err error)
+	// ReportStamp 持久化 workerId 的时间戳下界, 租约服务崩溃后下一个持有者以此为准
+	ReportStamp(workerId int, stamp int64) error
+	// Release 释放 workerId, stamp 为其已发出ID的最大时间戳(毫秒), 下一个持有者在时钟追上之前不发号
+	Release(workerId int, stamp int64) error
+	// LastStamp 上一个持有者释放时的时间戳, 从未使用过时返回0
+	LastStamp(workerId int) (int64, error)
+}
+
+// ZkBackend 由 ZkServer 分配, workerId 是该 ZkServer 会话下的临时节点
+// 释放时写入时间戳和墓碑, 冷却期内不会分配给其他主机
+func ZkBackend(srv *zkServer.ZkServer) Backend {
+	return zkBackend{srv}
+}
+
+type zkBackend struct {
+	srv *zkServer.ZkServer
+}
+
+func (b zkBackend) Acquire() (int, error) {
+	return b.srv.GetWorkerId()
+}
+
+func (b zkBackend) ReportStamp(workerId int, stamp int64) error {
+	return b.srv.ReportStamp(workerId, stamp)
+}
+
+// Release stamp 为0时(未知)保留已上报的时间戳, 不覆盖为更小的值
+func (b zkBackend) Release(workerId int, stamp int64) error {
+	if stamp > 0 {
+		if err := b.srv.ReportStamp(workerId, stamp); err != nil {
+			return err
+		}
+	}
+	return b.srv.ReleaseWorkerId(workerId)
+}
+
+func (b zkBackend) LastStamp(workerId int) (int64, error) {
+	return b.srv.GetLastStamp(workerId)
+}
+
+// memBackend 进程内分配, 服务重启后状态丢失, 适合单实例部署和测试
+type memBackend struct {
+	mu     sync.Mutex
+	used   map[int]bool
+	stamps map[int]int64
+}
+
+// NewMemBackend 进程内分配器, reserved 为不参与分配的 workerId, 例如本服务自己使用的ID
+func NewMemBackend(reserved ...int) Backend {
+	b := &memBackend{used: map[int]bool{}, stamps: map[int]int64{}}
+	for _, id := range reserved {
+		b.used[id] = true
+	}
+	return b
+}
+
+// Acquire 优先分配从未使用过的ID, 其次是最早释放的ID
+func (b *memBackend) Acquire() (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	best := -1
+	for id := 0; id <= int(common.MaxWorkerID); id++ {
+		if b.used[id] {
+			continue
+		}
+		if best < 0 || b.stamps[id] < b.stamps[best] {
+			best = id
+		}
+		if b.stamps[id] == 0 {
+			break
+		}
+	}
+	if best < 0 {
+		return 0, common.WorkerIdExhaustedErr
+	}
+	b.used[best] = true
+	return best, nil
+}
+
+func (b *memBackend) ReportStamp(workerId int, stamp int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if stamp > b.stamps[workerId] {
+		b.stamps[workerId] = stamp
+	}
+	return nil
+}
+
+func (b *memBackend) Release(workerId int, stamp int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.used, workerId)
+	if stamp > b.stamps[workerId] {
+		b.stamps[workerId] = stamp
+	}
+	return nil
+}
+
+func (b *memBackend) LastStamp(workerId int) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stamps[workerId], nil
+}
@@ -0,0 +1,98 @@
+package leaseServer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	snowFlake "github.com/lypee/snowFlake"
+	"github.com/lypee/snowFlake/common"
+)
+
+// Client 租约服务的客户端, 实现 snowFlake.Allocator:
+//
+//	sf, err := snowFlake.NewWorker(snowFlake.WithAllocator(leaseServer.NewClient("http://10.0.0.1:8090", nil)))
+type Client struct {
+	baseURL string
+	hc      *http.Client
+}
+
+var _ snowFlake.Allocator = (*Client)(nil)
+
+// NewClient hc 为空时使用 http.DefaultClient
+func NewClient(baseURL string, hc *http.Client) *Client {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &Client{baseURL: strings.TrimRight(baseURL, "/"), hc: hc}
+}
+
+func (c *Client) Acquire(ctx context.Context) (snowFlake.Lease, error) {
+	var body leaseBody
+	if err := c.do(ctx, http.MethodPost, "/leases", nil, http.StatusCreated, &body); err != nil {
+		return snowFlake.Lease{}, err
+	}
+	return body.lease(), nil
+}
+
+func (c *Client) Renew(ctx context.Context, lease snowFlake.Lease, stamp int64) (snowFlake.Lease, error) {
+	var body leaseBody
+	if err := c.do(ctx, http.MethodPut, "/leases/"+lease.ID, &stampBody{Stamp: stamp}, http.StatusOK, &body); err != nil {
+		return snowFlake.Lease{}, err
+	}
+	renewed := body.lease()
+	renewed.LastStamp = lease.LastStamp
+	return renewed, nil
+}
+
+func (c *Client) Release(ctx context.Context, lease snowFlake.Lease, stamp int64) error {
+	return c.do(ctx, http.MethodDelete, "/leases/"+lease.ID, &stampBody{Stamp: stamp}, http.StatusNoContent, nil)
+}
+
+func (b leaseBody) lease() snowFlake.Lease {
+	return snowFlake.Lease{
+		ID:           b.ID,
+		WorkerID:     b.WorkerID,
+		DataCenterID: b.DataCenterID,
+		TTL:          time.Duration(b.TTL) * time.Millisecond,
+		LastStamp:    b.LastStamp,
+	}
+}
+
+// do 状态码不是 want 时按响应中的错误码返回 common.Err, 例如租约已失效时为 LeaseConflictErr
+func (c *Client) do(ctx context.Context, method, path string, in interface{}, want int, out interface{}) error {
+	var reqBody bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&reqBody).Encode(in); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, &reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != want {
+		var e errBody
+		json.NewDecoder(resp.Body).Decode(&e)
+		cause := fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, e.Msg)
+		if ce, ok := common.LookupErr(e.Code); ok {
+			return ce.WithTrueErr(cause)
+		}
+		return common.OpErr.WithTrueErr(cause)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,275 @@
+package leaseServer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lypee/snowFlake/base"
+	"github.com/lypee/snowFlake/common"
+)
+
+// leaseBody 租约的 JSON 格式
+type leaseBody struct {
+	ID           string `json:"id"`
+	WorkerID     int64  `json:"workerId"`
+	DataCenterID int64  `json:"dataCenterId"`
+	TTL          int64  `json:"ttlMs"`
+	LastStamp    int64  `json:"lastStamp"`
+}
+
+// stampBody 续约和释放的请求体, Stamp 为客户端已发出ID的最大时间戳(毫秒)
+type stampBody struct {
+	Stamp int64 `json:"stamp"`
+}
+
+// DefaultTTL 未指定 TTL 时租约的有效期
+const DefaultTTL = 30 * time.Second
+
+type errBody struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+type lease struct {
+	workerId int
+	stamp    int64 // 客户端上报的最大时间戳
+	expires  time.Time
+	dropped  bool // 客户端已释放, 后端释放失败时由回收任务重试
+}
+
+// Server workerId 租约服务, 应用内嵌 SfWorker 时通过它获取身份, 不必直接访问 zk
+//
+//	POST   /leases        申请, 201 返回租约
+//	PUT    /leases/{id}   续约, 租约不存在或已过期时返回404
+//	DELETE /leases/{id}   释放, 204
+//
+// 未续约的租约在 TTL 后回收, 以到期时间作为其最终时间戳释放, 下一个持有者在此之前不发号;
+// 申请和续约时先把 max(客户端时间戳, 到期时间) 写入后端, 本服务崩溃后下一个持有者同样在此之前不发号
+type Server struct {
+	backend      Backend
+	dataCenterID int64
+	ttl          time.Duration
+
+	mu     sync.Mutex
+	leases map[string]*lease
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New 创建租约服务并启动过期回收, 发出的租约都属于数据中心 dataCenterID; ttl 不大于0时使用 DefaultTTL
+func New(b Backend, dataCenterID int64, ttl time.Duration) *Server {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	s := &Server{
+		backend:      b,
+		dataCenterID: dataCenterID,
+		ttl:          ttl,
+		leases:       map[string]*lease{},
+		stopCh:       make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.sweep()
+	return s
+}
+
+// Close 停止回收并释放所有未到期的租约, 以到期时间作为最终时间戳, 持有者在到期前仍可发号
+func (s *Server) Close() {
+	close(s.stopCh)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, l := range s.leases {
+		if l.dropped {
+			s.release(id, l, l.stamp)
+			continue
+		}
+		s.release(id, l, maxStamp(l.stamp, millis(l.expires)))
+	}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/leases", s.handleLeases)
+	mux.HandleFunc("/leases/", s.handleLease)
+	return mux
+}
+
+func (s *Server) handleLeases(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		writeJSON(rw, http.StatusMethodNotAllowed, errBody{Code: http.StatusMethodNotAllowed, Msg: "method not allowed"})
+		return
+	}
+	body, err := s.acquire()
+	if err != nil {
+		writeErr(rw, err)
+		return
+	}
+	writeJSON(rw, http.StatusCreated, body)
+}
+
+func (s *Server) handleLease(rw http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/leases/")
+	var req stampBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeJSON(rw, http.StatusBadRequest, errBody{Code: http.StatusBadRequest, Msg: "invalid body"})
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		body, err := s.renew(id, req.Stamp)
+		if err != nil {
+			writeErr(rw, err)
+			return
+		}
+		writeJSON(rw, http.StatusOK, body)
+	case http.MethodDelete:
+		if err := s.drop(id, req.Stamp); err != nil {
+			writeErr(rw, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	default:
+		rw.Header().Set("Allow", http.MethodPut+", "+http.MethodDelete)
+		writeJSON(rw, http.StatusMethodNotAllowed, errBody{Code: http.StatusMethodNotAllowed, Msg: "method not allowed"})
+	}
+}
+
+func (s *Server) acquire() (*leaseBody, error) {
+	workerId, err := s.backend.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	lastStamp, err := s.backend.LastStamp(workerId)
+	if err != nil {
+		s.backend.Release(workerId, 0)
+		return nil, err
+	}
+	expires := time.Now().Add(s.ttl)
+	if err = s.backend.ReportStamp(workerId, millis(expires)); err != nil {
+		s.backend.Release(workerId, 0)
+		return nil, err
+	}
+	id := newLeaseID()
+	s.mu.Lock()
+	s.leases[id] = &lease{workerId: workerId, expires: expires}
+	s.mu.Unlock()
+	base.InfoF("worker lease granted, lease:[%s], workerId:[%d]", id, workerId)
+	return &leaseBody{ID: id, WorkerID: int64(workerId), DataCenterID: s.dataCenterID, TTL: s.ttl.Milliseconds(), LastStamp: lastStamp}, nil
+}
+
+// renew 新的到期时间写入后端后才生效, 写入失败时租约仍在原来的到期时间过期
+func (s *Server) renew(id string, stamp int64) (*leaseBody, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.leases[id]
+	if !ok || l.dropped {
+		return nil, common.LeaseConflictErr
+	}
+	l.stamp = maxStamp(l.stamp, stamp)
+	expires := time.Now().Add(s.ttl)
+	if err := s.backend.ReportStamp(l.workerId, maxStamp(l.stamp, millis(expires))); err != nil {
+		base.WarningF("report lease stamp err:[%+v], lease:[%s], workerId:[%d]", err, id, l.workerId)
+		return nil, err
+	}
+	l.expires = expires
+	return &leaseBody{ID: id, WorkerID: int64(l.workerId), DataCenterID: s.dataCenterID, TTL: s.ttl.Milliseconds()}, nil
+}
+
+func (s *Server) drop(id string, stamp int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.leases[id]
+	if !ok {
+		return common.LeaseConflictErr
+	}
+	// 上次释放失败后客户端重试时再次尝试
+	l.stamp = maxStamp(l.stamp, stamp)
+	l.dropped = true
+	return s.release(id, l, l.stamp)
+}
+
+// release 调用方持有 s.mu; 后端释放失败时保留租约, 由回收任务重试, 避免 workerId 泄漏
+func (s *Server) release(id string, l *lease, stamp int64) error {
+	if err := s.backend.Release(l.workerId, stamp); err != nil {
+		base.WarningF("release workerId err:[%+v], lease:[%s], workerId:[%d]", err, id, l.workerId)
+		return err
+	}
+	delete(s.leases, id)
+	base.InfoF("worker lease released, lease:[%s], workerId:[%d]", id, l.workerId)
+	return nil
+}
+
+// sweep 回收过期的租约
+func (s *Server) sweep() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.ttl / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		s.mu.Lock()
+		for id, l := range s.leases {
+			switch {
+			case l.dropped:
+				s.release(id, l, l.stamp)
+			case now.After(l.expires):
+				base.WarningF("worker lease expired, lease:[%s], workerId:[%d]", id, l.workerId)
+				s.release(id, l, maxStamp(l.stamp, millis(l.expires)))
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func maxStamp(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / 1e6
+}
+
+func newLeaseID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// writeErr 租约不存在时返回404, workerId 用完或分配器不可用时返回503
+func writeErr(rw http.ResponseWriter, err error) {
+	status := http.StatusServiceUnavailable
+	if errors.Is(err, common.LeaseConflictErr) {
+		status = http.StatusNotFound
+	}
+	body := errBody{Code: common.OpErr.Code, Msg: err.Error()}
+	var e common.Err
+	if errors.As(err, &e) {
+		body.Code = e.Code
+	}
+	writeJSON(rw, status, body)
+}
+
+func writeJSON(rw http.ResponseWriter, status int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		base.WarningF("write response err:[%+v]", err)
+	}
+}
@@ -0,0 +1,242 @@
+package leaseServer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	snowFlake "github.com/lypee/snowFlake"
+	"github.com/lypee/snowFlake/common"
+)
+
+func newTestServer(t *testing.T, ttl time.Duration, reserved ...int) (*Server, *httptest.Server) {
+	t.Helper()
+	srv := New(NewMemBackend(reserved...), 5, ttl)
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(func() {
+		ts.Close()
+		srv.Close()
+	})
+	return srv, ts
+}
+
+func TestServer_api(t *testing.T) {
+	_, ts := newTestServer(t, time.Minute, 0)
+	c := NewClient(ts.URL, nil)
+	ctx := context.Background()
+
+	lease, err := c.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.ID == "" || lease.WorkerID != 1 || lease.DataCenterID != 5 || lease.TTL != time.Minute {
+		t.Fatalf("Acquire: %+v", lease)
+	}
+	other, err := c.Acquire(ctx)
+	if err != nil || other.WorkerID == lease.WorkerID {
+		t.Fatalf("second Acquire: %+v %v", other, err)
+	}
+	if _, err = c.Renew(ctx, lease, 100); err != nil {
+		t.Fatalf("Renew: %v", err)
+	}
+
+	stamp := time.Now().Add(time.Hour).UnixNano() / 1e6
+	if err = c.Release(ctx, lease, stamp); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if _, err = c.Renew(ctx, lease, 0); !errors.Is(err, common.LeaseConflictErr) {
+		t.Fatalf("Renew after Release: %v", err)
+	}
+	if err = c.Release(ctx, lease, 0); !errors.Is(err, common.LeaseConflictErr) {
+		t.Fatalf("second Release: %v", err)
+	}
+
+	resp, err := http.Get(ts.URL + "/leases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("GET /leases: %d", resp.StatusCode)
+	}
+}
+
+func TestServer_expire(t *testing.T) {
+	srv, ts := newTestServer(t, 100*time.Millisecond)
+	c := NewClient(ts.URL, nil)
+	ctx := context.Background()
+
+	before := time.Now().UnixNano() / 1e6
+	lease, err := c.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if _, err = c.Renew(ctx, lease, 0); !errors.Is(err, common.LeaseConflictErr) {
+		t.Fatalf("Renew after expiry: %v", err)
+	}
+	// 回收时以到期时间作为最终时间戳, 下一个持有者在此之前不发号
+	if last, _ := srv.backend.LastStamp(int(lease.WorkerID)); last < before+100 {
+		t.Fatalf("expired lease released with stamp %d, want >= %d", last, before+100)
+	}
+}
+
+func TestWorker_withAllocator(t *testing.T) {
+	srv, ts := newTestServer(t, 300*time.Millisecond)
+	sf, err := snowFlake.NewWorker(snowFlake.WithAllocator(NewClient(ts.URL, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := sf.Status()
+	if st.DataCenterID != 5 {
+		t.Fatalf("identity should come from the lease: %+v", st)
+	}
+	// 超过 TTL 后仍可发号, 说明在续约
+	time.Sleep(time.Second)
+	id, err := sf.NextID()
+	if err != nil {
+		t.Fatalf("NextID after several TTLs: %v", err)
+	}
+	if p := sf.Decode(id); p.WorkerID != st.WorkerID || p.DataCenterID != 5 {
+		t.Fatalf("unexpected id %+v", p)
+	}
+
+	if err = sf.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	srv.mu.Lock()
+	n := len(srv.leases)
+	srv.mu.Unlock()
+	if n != 0 {
+		t.Fatalf("Close should release the lease, %d left", n)
+	}
+	last, _ := srv.backend.LastStamp(int(st.WorkerID))
+	if last < sf.Decode(id).Timestamp {
+		t.Fatalf("released stamp %d is before the last id %d", last, sf.Decode(id).Timestamp)
+	}
+}
+
+func TestWorker_leaseRevoked(t *testing.T) {
+	srv, ts := newTestServer(t, 300*time.Millisecond)
+	sf, err := snowFlake.NewWorker(snowFlake.WithAllocator(NewClient(ts.URL, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sf.Close(context.Background())
+
+	srv.mu.Lock()
+	for id, l := range srv.leases {
+		srv.release(id, l, 0)
+	}
+	srv.mu.Unlock()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !sf.Status().LeaseLost {
+		if time.Now().After(deadline) {
+			t.Fatal("worker should notice the revoked lease")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if _, err = sf.NextID(); !errors.Is(err, snowFlake.ErrLeaseLost) {
+		t.Fatalf("NextID after revoke: %v", err)
+	}
+}
+
+// flakyBackend 记录上报的时间戳, failRelease 为 true 时释放失败
+type flakyBackend struct {
+	Backend
+	mu          sync.Mutex
+	reported    map[int]int64
+	failRelease bool
+}
+
+func (b *flakyBackend) ReportStamp(workerId int, stamp int64) error {
+	b.mu.Lock()
+	b.reported[workerId] = stamp
+	b.mu.Unlock()
+	return b.Backend.ReportStamp(workerId, stamp)
+}
+
+func (b *flakyBackend) Release(workerId int, stamp int64) error {
+	b.mu.Lock()
+	fail := b.failRelease
+	b.mu.Unlock()
+	if fail {
+		return errors.New("backend unavailable")
+	}
+	return b.Backend.Release(workerId, stamp)
+}
+
+func TestServer_durability(t *testing.T) {
+	b := &flakyBackend{Backend: NewMemBackend(), reported: map[int]int64{}}
+	srv := New(b, 5, 200*time.Millisecond)
+	ts := httptest.NewServer(srv.Handler())
+	defer func() {
+		ts.Close()
+		srv.Close()
+	}()
+	c := NewClient(ts.URL, nil)
+	ctx := context.Background()
+
+	before := time.Now().UnixNano() / 1e6
+	lease, err := c.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.mu.Lock()
+	got := b.reported[int(lease.WorkerID)]
+	b.mu.Unlock()
+	if got < before+200 {
+		t.Fatalf("Acquire reported %d, want >= %d", got, before+200)
+	}
+	// 客户端时间戳超过到期时间时以客户端为准
+	future := time.Now().Add(time.Hour).UnixNano() / 1e6
+	if _, err = c.Renew(ctx, lease, future); err != nil {
+		t.Fatal(err)
+	}
+	b.mu.Lock()
+	got = b.reported[int(lease.WorkerID)]
+	b.failRelease = true
+	b.mu.Unlock()
+	if got != future {
+		t.Fatalf("Renew reported %d, want %d", got, future)
+	}
+
+	if err = c.Release(ctx, lease, future); err == nil {
+		t.Fatal("Release should fail while the backend is unavailable")
+	}
+	srv.mu.Lock()
+	n := len(srv.leases)
+	srv.mu.Unlock()
+	if n != 1 {
+		t.Fatalf("failed release should keep the lease, %d left", n)
+	}
+	if _, err = c.Renew(ctx, lease, 0); !errors.Is(err, common.LeaseConflictErr) {
+		t.Fatalf("Renew after Release: %v", err)
+	}
+
+	// 后端恢复后由回收任务完成释放
+	b.mu.Lock()
+	b.failRelease = false
+	b.mu.Unlock()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		srv.mu.Lock()
+		n = len(srv.leases)
+		srv.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("sweep should retry the failed release")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if last, _ := b.LastStamp(int(lease.WorkerID)); last != future {
+		t.Fatalf("released stamp %d, want %d", last, future)
+	}
+}
@@ -0,0 +1,100 @@
+package respServer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxArgs    = 1024
+	maxBulkLen = 512 * 1024
+)
+
+var errProtocol = errors.New("protocol error")
+
+// readCommand 读取一条命令: RESP 数组形式(*N\r\n$len\r\narg\r\n...), 或以空格分隔的 inline 形式(便于 telnet 调试)
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, nil
+	}
+	if line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 || n > maxArgs {
+		return nil, errProtocol
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err = readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, errProtocol
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, errProtocol
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if buf[size] != '\r' || buf[size+1] != '\n' {
+			return nil, errProtocol
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// writer RESP2 回复
+type writer struct {
+	w *bufio.Writer
+}
+
+func (w writer) simple(s string) {
+	fmt.Fprintf(w.w, "+%s\r\n", s)
+}
+
+func (w writer) err(s string) {
+	fmt.Fprintf(w.w, "-%s\r\n", strings.ReplaceAll(s, "\r\n", " "))
+}
+
+func (w writer) integer(n int64) {
+	fmt.Fprintf(w.w, ":%d\r\n", n)
+}
+
+func (w writer) bulk(s string) {
+	fmt.Fprintf(w.w, "$%d\r\n%s\r\n", len(s), s)
+}
+
+func (w writer) array(n int) {
+	fmt.Fprintf(w.w, "*%d\r\n", n)
+}
+
+// id 不超过 int64 时以整数回复, 否则以字符串回复
+func (w writer) id(id uint64) {
+	if id > uint64(1<<63-1) {
+		w.bulk(strconv.FormatUint(id, 10))
+		return
+	}
+	w.integer(int64(id))
+}
@@ -0,0 +1,319 @@
+package respServer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	snowFlake "github.com/lypee/snowFlake"
+	"github.com/lypee/snowFlake/base"
+	"github.com/lypee/snowFlake/common"
+)
+
+// Generator RESP 服务依赖的发号器, *snowFlake.SfWorker 实现了该接口
+type Generator interface {
+	NextID() (uint64, error)
+	NextIDs(n int) ([]uint64, error)
+	Decode(id uint64) snowFlake.IDParts
+}
+
+// Server 兼容 Redis 协议(RESP2)的发号服务, 任何 Redis 客户端都可以直接取号:
+//
+//	INCR <bizTag>                 一个ID, 整数回复
+//	MGET <bizTag> [<bizTag> ...]  每个key一个ID, 数组回复
+//	SNOWFLAKE.DECODE <id>         [timestamp, dataCenterId, workerId, sequence] 的字段/值数组
+//	PING / ECHO / QUIT / SELECT / CLIENT / COMMAND
+//
+// 调用 HandleTags 后 bizTag 为 Registry 中登记的业务, 各自使用其布局发号, 未登记的业务返回错误;
+// 否则所有key共用同一个发号器
+type Server struct {
+	gen  Generator
+	tags *snowFlake.Registry
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+func New(gen Generator) *Server {
+	return &Server{gen: gen, conns: map[net.Conn]struct{}{}}
+}
+
+// HandleTags INCR/MGET 按 bizTag 从 r 中取发号器, SNOWFLAKE.DECODE 可以带上 bizTag; 需在 Serve 之前调用
+func (s *Server) HandleTags(r *snowFlake.Registry) {
+	s.tags = r
+}
+
+// ListenAndServe 监听 addr, 阻塞直到 Shutdown, 正常关闭时返回 nil
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	base.InfoF("resp server listen on %s", addr)
+	return s.Serve(l)
+}
+
+// Serve 在 l 上提供服务, 正常关闭时返回 nil
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return nil
+	}
+	s.listener = l
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		if !s.track(conn) {
+			conn.Close()
+			return nil
+		}
+		go s.handle(conn)
+	}
+}
+
+// Shutdown 停止接收新连接并关闭空闲连接, 等待正在处理的命令完成; ctx 到期后强制关闭
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	// 连接在读取下一条命令时会因超时返回
+	for c := range s.conns {
+		c.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for c := range s.conns {
+			c.Close()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (s *Server) track(c net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[c] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Server) untrack(c net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, c)
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer s.untrack(c)
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+	w := writer{w: bufio.NewWriter(c)}
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if err == errProtocol {
+				w.err("ERR " + err.Error())
+				w.w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if !s.exec(w, args) {
+			w.w.Flush()
+			return
+		}
+		// 客户端流水线发送多条命令时合并写回
+		if r.Buffered() == 0 {
+			if err = w.w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// exec 执行一条命令, 返回 false 表示关闭连接
+func (s *Server) exec(w writer, args []string) bool {
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		if len(args) > 1 {
+			w.bulk(args[1])
+		} else {
+			w.simple("PONG")
+		}
+	case "ECHO":
+		if !arity(w, args, 2) {
+			break
+		}
+		w.bulk(args[1])
+	case "QUIT":
+		w.simple("OK")
+		return false
+	case "SELECT", "CLIENT":
+		w.simple("OK")
+	case "COMMAND":
+		w.array(0)
+	case "INCR":
+		if !arity(w, args, 2) {
+			break
+		}
+		g, err := s.generator(args[1])
+		if err != nil {
+			writeErr(w, err)
+			break
+		}
+		id, err := g.NextID()
+		if err != nil {
+			writeErr(w, err)
+			break
+		}
+		w.id(id)
+	case "MGET":
+		if len(args) < 2 {
+			w.err("ERR wrong number of arguments for 'mget' command")
+			break
+		}
+		ids, err := s.mget(args[1:])
+		if err != nil {
+			writeErr(w, err)
+			break
+		}
+		w.array(len(ids))
+		for _, id := range ids {
+			w.bulk(strconv.FormatUint(id, 10))
+		}
+	case "SNOWFLAKE.DECODE":
+		if len(args) != 2 && len(args) != 3 {
+			w.err("ERR wrong number of arguments for 'snowflake.decode' command")
+			break
+		}
+		id, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			w.err("ERR value is not a valid id")
+			break
+		}
+		g := s.gen
+		if len(args) == 3 {
+			if g, err = s.generator(args[2]); err != nil {
+				writeErr(w, err)
+				break
+			}
+		}
+		p := g.Decode(id)
+		w.array(8)
+		w.bulk("timestamp")
+		w.integer(p.Timestamp)
+		w.bulk("dataCenterId")
+		w.integer(p.DataCenterID)
+		w.bulk("workerId")
+		w.integer(p.WorkerID)
+		w.bulk("sequence")
+		w.integer(p.Sequence)
+	default:
+		w.err("ERR unknown command '" + args[0] + "'")
+	}
+	return true
+}
+
+// generator bizTag 对应的发号器, 未调用 HandleTags 时所有key共用 s.gen
+func (s *Server) generator(tag string) (Generator, error) {
+	if s.tags == nil {
+		return s.gen, nil
+	}
+	g, ok := s.tags.Get(tag)
+	if !ok {
+		return nil, common.UnknownBizTagErr.WithTrueErr(fmt.Errorf("biz_tag %q", tag))
+	}
+	return g, nil
+}
+
+// mget 每个key一个ID, 同一业务的key批量取号; 有未登记的业务时不发号
+func (s *Server) mget(tags []string) ([]uint64, error) {
+	gens := make([]Generator, len(tags))
+	counts := map[Generator]int{}
+	for i, tag := range tags {
+		g, err := s.generator(tag)
+		if err != nil {
+			return nil, err
+		}
+		gens[i] = g
+		counts[g]++
+	}
+	batches := make(map[Generator][]uint64, len(counts))
+	for g, n := range counts {
+		ids, err := g.NextIDs(n)
+		if err != nil {
+			return nil, err
+		}
+		batches[g] = ids
+	}
+	res := make([]uint64, len(tags))
+	for i, g := range gens {
+		res[i] = batches[g][0]
+		batches[g] = batches[g][1:]
+	}
+	return res, nil
+}
+
+func arity(w writer, args []string, n int) bool {
+	if len(args) == n {
+		return true
+	}
+	w.err("ERR wrong number of arguments for '" + strings.ToLower(args[0]) + "' command")
+	return false
+}
+
+// writeErr 暂时不可用的错误以 TRYAGAIN 开头, 便于客户端重试
+func writeErr(w writer, err error) {
+	prefix := "ERR "
+	if errors.Is(err, common.LeaseConflictErr) || errors.Is(err, common.WorkerClosedErr) ||
+		errors.Is(err, common.ClockBackwardsErr) || errors.Is(err, common.SequenceExhaustedErr) {
+		prefix = "TRYAGAIN "
+	}
+	w.err(prefix + err.Error())
+}
@@ -0,0 +1,224 @@
+package respServer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	snowFlake "github.com/lypee/snowFlake"
+)
+
+func newTestServer(t *testing.T) (*snowFlake.SfWorker, *Server, string) {
+	t.Helper()
+	sf, err := snowFlake.NewWorker(snowFlake.WithDataCenterId(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := New(sf)
+	go srv.Serve(l)
+	t.Cleanup(func() {
+		srv.Shutdown(context.Background())
+		sf.Close(context.Background())
+	})
+	return sf, srv, l.Addr().String()
+}
+
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, addr string) *client {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return &client{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *client) do(t *testing.T, args ...string) interface{} {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		b.WriteString("$" + strconv.Itoa(len(a)) + "\r\n" + a + "\r\n")
+	}
+	if _, err := c.conn.Write([]byte(b.String())); err != nil {
+		t.Fatal(err)
+	}
+	return c.read(t)
+}
+
+// read 解析一条回复: 简单字符串/错误为 string(错误带 "-" 前缀), 整数为 int64, 数组为 []interface{}
+func (c *client) read(t *testing.T) interface{} {
+	t.Helper()
+	line, err := readLine(c.r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch line[0] {
+	case '+':
+		return line[1:]
+	case '-':
+		return line
+	case ':':
+		n, _ := strconv.ParseInt(line[1:], 10, 64)
+		return n
+	case '$':
+		s, _ := readLine(c.r)
+		return s
+	case '*':
+		n, _ := strconv.Atoi(line[1:])
+		res := make([]interface{}, n)
+		for i := range res {
+			res[i] = c.read(t)
+		}
+		return res
+	}
+	t.Fatalf("unexpected reply %q", line)
+	return nil
+}
+
+func TestServer_commands(t *testing.T) {
+	sf, _, addr := newTestServer(t)
+	c := dial(t, addr)
+
+	if r := c.do(t, "PING"); r != "PONG" {
+		t.Fatalf("PING: %v", r)
+	}
+	id, ok := c.do(t, "INCR", "order").(int64)
+	if !ok || id <= 0 {
+		t.Fatalf("INCR: %v", id)
+	}
+	ids, ok := c.do(t, "mget", "a", "b", "c").([]interface{})
+	if !ok || len(ids) != 3 {
+		t.Fatalf("MGET: %v", ids)
+	}
+	prev := uint64(id)
+	for _, v := range ids {
+		n, err := strconv.ParseUint(v.(string), 10, 64)
+		if err != nil || n <= prev {
+			t.Fatalf("MGET ids not increasing: %v", ids)
+		}
+		prev = n
+	}
+
+	parts, ok := c.do(t, "SNOWFLAKE.DECODE", strconv.FormatInt(id, 10)).([]interface{})
+	if !ok || len(parts) != 8 {
+		t.Fatalf("SNOWFLAKE.DECODE: %v", parts)
+	}
+	want := sf.Decode(uint64(id))
+	if parts[0] != "timestamp" || parts[1] != want.Timestamp || parts[3] != int64(2) || parts[5] != want.WorkerID {
+		t.Fatalf("SNOWFLAKE.DECODE: %v, want %+v", parts, want)
+	}
+
+	for _, args := range [][]string{{"INCR"}, {"MGET"}, {"SNOWFLAKE.DECODE", "x"}, {"SET", "k", "v"}} {
+		if r, _ := c.do(t, args...).(string); !strings.HasPrefix(r, "-ERR") {
+			t.Fatalf("%v: %v", args, r)
+		}
+	}
+	if r := c.do(t, "QUIT"); r != "OK" {
+		t.Fatalf("QUIT: %v", r)
+	}
+}
+
+func TestServer_tags(t *testing.T) {
+	sf, srv, addr := newTestServer(t)
+	reg := snowFlake.NewRegistry(sf)
+	users, err := reg.Register("users", snowFlake.Layout{SequenceBits: 6, ReservedBits: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = reg.Register("orders", snowFlake.Layout{}); err != nil {
+		t.Fatal(err)
+	}
+	srv.HandleTags(reg)
+	c := dial(t, addr)
+
+	id, ok := c.do(t, "INCR", "users").(int64)
+	if !ok || uint64(id)&0xf != 0 || users.Decode(uint64(id)).DataCenterID != 2 {
+		t.Fatalf("INCR users: %v", id)
+	}
+	ids, ok := c.do(t, "MGET", "users", "orders", "users").([]interface{})
+	if !ok || len(ids) != 3 {
+		t.Fatalf("MGET: %v", ids)
+	}
+	first, _ := strconv.ParseUint(ids[0].(string), 10, 64)
+	last, _ := strconv.ParseUint(ids[2].(string), 10, 64)
+	if first&0xf != 0 || last&0xf != 0 || last <= first {
+		t.Fatalf("MGET users ids: %v", ids)
+	}
+	parts, ok := c.do(t, "SNOWFLAKE.DECODE", strconv.FormatInt(id, 10), "users").([]interface{})
+	if want := users.Decode(uint64(id)); !ok || parts[1] != want.Timestamp || parts[7] != want.Sequence {
+		t.Fatalf("SNOWFLAKE.DECODE users: %v, want %+v", parts, want)
+	}
+
+	for _, args := range [][]string{{"INCR", "missing"}, {"MGET", "users", "missing"}, {"SNOWFLAKE.DECODE", "1", "missing"}} {
+		if r, _ := c.do(t, args...).(string); !strings.HasPrefix(r, "-ERR") || !strings.Contains(r, "missing") {
+			t.Fatalf("%v: %v", args, r)
+		}
+	}
+	if n := users.Stats().Issued; n != 3 {
+		t.Fatalf("MGET with an unknown tag should not issue ids, users issued %d", n)
+	}
+}
+
+func TestServer_inlineAndPipeline(t *testing.T) {
+	_, _, addr := newTestServer(t)
+	c := dial(t, addr)
+
+	if _, err := c.conn.Write([]byte("PING\r\nINCR x\r\n*1\r\n$4\r\nPING\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if r := c.read(t); r != "PONG" {
+		t.Fatalf("inline PING: %v", r)
+	}
+	if _, ok := c.read(t).(int64); !ok {
+		t.Fatal("inline INCR: not an integer")
+	}
+	if r := c.read(t); r != "PONG" {
+		t.Fatalf("pipelined PING: %v", r)
+	}
+}
+
+func TestServer_shutdown(t *testing.T) {
+	sf, err := snowFlake.NewWorker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sf.Close(context.Background())
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := New(sf)
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(l) }()
+
+	c := dial(t, l.Addr().String())
+	if r := c.do(t, "PING"); r != "PONG" {
+		t.Fatalf("PING: %v", r)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err = srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err = <-served; err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	if _, err = c.r.ReadByte(); err == nil {
+		t.Fatal("idle connection should be closed after Shutdown")
+	}
+}
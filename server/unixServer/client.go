@@ -0,0 +1,98 @@
+package unixServer
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Client sidecar 的客户端, 方法签名与 SfWorker 一致, 可以并发使用
+// 请求在一条连接上串行发送, 连接出错后下次调用时重连
+type Client struct {
+	path    string
+	timeout time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// Dial 连接 path 上的 sidecar, timeout 为单次请求的超时, 0表示不超时
+func Dial(path string, timeout time.Duration) (*Client, error) {
+	c := &Client{path: path, timeout: timeout}
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) connect() error {
+	conn, err := net.DialTimeout("unix", c.path, c.dialTimeout())
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	c.r = bufio.NewReader(conn)
+	return nil
+}
+
+func (c *Client) dialTimeout() time.Duration {
+	if c.timeout > 0 {
+		return c.timeout
+	}
+	return 5 * time.Second
+}
+
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func (c *Client) NextID() (uint64, error) {
+	ids, err := c.NextIDs(1)
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+func (c *Client) NextIDs(n int) ([]uint64, error) {
+	if n < 1 || n > MaxBatch {
+		return nil, fmt.Errorf("unixServer: count must be in [1, %d]", MaxBatch)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+	}
+	if c.timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+	ids, err := c.roundTrip(n)
+	if err != nil {
+		if _, ok := err.(*RemoteError); !ok {
+			// 连接状态未知, 丢弃
+			c.conn.Close()
+			c.conn = nil
+		}
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (c *Client) roundTrip(n int) ([]uint64, error) {
+	if err := writeRequest(c.conn, n); err != nil {
+		return nil, err
+	}
+	return readResponse(c.r, make([]uint64, 0, n))
+}
@@ -0,0 +1,112 @@
+package unixServer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+// 帧格式, 整数均为大端序:
+//
+//	请求        count uint32
+//	成功响应    count uint32, count 个 uint64
+//	失败响应    0 uint32, code uint32, msgLen uint16, msg
+//
+// 失败时 code 为 common.Err 的错误码
+
+// MaxBatch 单次请求最多的ID个数
+const MaxBatch = 10000
+
+const maxMsgLen = 1<<16 - 1
+
+func writeRequest(w io.Writer, count int) error {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], uint32(count))
+	_, err := w.Write(buf[:])
+	return err
+}
+
+func readRequest(r io.Reader) (int, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint32(buf[:])), nil
+}
+
+// appendIDs 把成功响应追加到 buf
+func appendIDs(buf []byte, ids []uint64) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(ids)))
+	for _, id := range ids {
+		buf = binary.BigEndian.AppendUint64(buf, id)
+	}
+	return buf
+}
+
+// appendErr 把失败响应追加到 buf
+func appendErr(buf []byte, err error) []byte {
+	code := common.OpErr.Code
+	var e common.Err
+	if errors.As(err, &e) {
+		code = e.Code
+	}
+	msg := err.Error()
+	if len(msg) > maxMsgLen {
+		msg = msg[:maxMsgLen]
+	}
+	buf = binary.BigEndian.AppendUint32(buf, 0)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(code))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(msg)))
+	return append(buf, msg...)
+}
+
+// readResponse 读取一个响应, 失败响应转换为 *RemoteError
+func readResponse(r io.Reader, ids []uint64) ([]uint64, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(r, hdr[:4]); err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint32(hdr[:4]))
+	if n == 0 {
+		if _, err := io.ReadFull(r, hdr[:6]); err != nil {
+			return nil, err
+		}
+		code := int(binary.BigEndian.Uint32(hdr[:4]))
+		msg := make([]byte, binary.BigEndian.Uint16(hdr[4:6]))
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return nil, err
+		}
+		return nil, &RemoteError{Code: code, Msg: string(msg)}
+	}
+	if n > MaxBatch {
+		return nil, fmt.Errorf("unixServer: response count %d exceeds %d", n, MaxBatch)
+	}
+	for i := 0; i < n; i++ {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return nil, err
+		}
+		ids = append(ids, binary.BigEndian.Uint64(hdr[:]))
+	}
+	return ids, nil
+}
+
+// RemoteError sidecar 返回的错误, 可以用 errors.Is(err, common.LeaseConflictErr) 按错误码判断
+type RemoteError struct {
+	Code int
+	Msg  string
+}
+
+func (e *RemoteError) Error() string {
+	return e.Msg
+}
+
+// Unwrap 返回登记的同码错误, 未登记时返回 nil
+func (e *RemoteError) Unwrap() error {
+	if ce, ok := common.LookupErr(e.Code); ok {
+		return ce
+	}
+	return nil
+}
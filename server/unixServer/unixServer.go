@@ -0,0 +1,172 @@
+package unixServer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lypee/snowFlake/base"
+	"github.com/lypee/snowFlake/common"
+)
+
+// Generator sidecar 依赖的发号器, *snowFlake.SfWorker 实现了该接口
+type Generator interface {
+	NextIDs(n int) ([]uint64, error)
+}
+
+// Server Unix domain socket 上的 sidecar 发号服务
+// 同一主机上的多个进程通过它共用一个 workerId, 不必各自租用, 帧格式见 frame.go
+type Server struct {
+	gen Generator
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+func New(gen Generator) *Server {
+	return &Server{gen: gen, conns: map[net.Conn]struct{}{}}
+}
+
+// Listen 在 path 上监听, 清理上次异常退出遗留的 socket 文件; path 仍有进程在监听时返回错误
+func Listen(path string) (net.Listener, error) {
+	if fi, err := os.Stat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("unixServer: %s exists and is not a socket", path)
+		}
+		if c, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("unixServer: %s is in use", path)
+		}
+		if err = os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// ListenAndServe 监听 path, 阻塞直到 Shutdown, 正常关闭时返回 nil
+func (s *Server) ListenAndServe(path string) error {
+	l, err := Listen(path)
+	if err != nil {
+		return err
+	}
+	base.InfoF("unix server listen on %s", path)
+	return s.Serve(l)
+}
+
+// Serve 在 l 上提供服务, 正常关闭时返回 nil; Shutdown 时关闭 l, unix listener 会删除 socket 文件
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return nil
+	}
+	s.listener = l
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		if !s.track(conn) {
+			conn.Close()
+			return nil
+		}
+		go s.handle(conn)
+	}
+}
+
+// Shutdown 停止接收新连接并关闭空闲连接, 等待正在处理的请求完成; ctx 到期后强制关闭
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	// 连接在读取下一个请求时会因超时返回
+	for c := range s.conns {
+		c.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for c := range s.conns {
+			c.Close()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (s *Server) track(c net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[c] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Server) untrack(c net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, c)
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer s.untrack(c)
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+	var buf []byte
+	for {
+		count, err := readRequest(r)
+		if err != nil {
+			return
+		}
+		buf = buf[:0]
+		if count < 1 || count > MaxBatch {
+			buf = appendErr(buf, common.OpErr.WithTrueErr(fmt.Errorf("count must be in [1, %d]", MaxBatch)))
+		} else if ids, err := s.gen.NextIDs(count); err != nil {
+			buf = appendErr(buf, err)
+		} else {
+			buf = appendIDs(buf, ids)
+		}
+		if _, err = c.Write(buf); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package unixServer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	snowFlake "github.com/lypee/snowFlake"
+	"github.com/lypee/snowFlake/common"
+)
+
+type failGen struct{ err error }
+
+func (g failGen) NextIDs(int) ([]uint64, error) { return nil, g.err }
+
+func startServer(t *testing.T, gen Generator) (*Server, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "sf.sock")
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := New(gen)
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+	return srv, path
+}
+
+func TestServer_nextIDs(t *testing.T) {
+	sf, err := snowFlake.NewWorker(snowFlake.WithDataCenterId(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sf.Close(context.Background())
+	_, path := startServer(t, sf)
+
+	c, err := Dial(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = map[uint64]bool{}
+	)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				ids, err := c.NextIDs(20)
+				if err != nil || len(ids) != 20 {
+					t.Errorf("NextIDs: %v %d", err, len(ids))
+					return
+				}
+				id, err := c.NextID()
+				if err != nil {
+					t.Errorf("NextID: %v", err)
+					return
+				}
+				mu.Lock()
+				for _, v := range append(ids, id) {
+					if seen[v] {
+						t.Errorf("duplicate id %d", v)
+					}
+					seen[v] = true
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	id, err := c.NextID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := sf.Decode(id); p.DataCenterID != 1 {
+		t.Fatalf("ids should come from the sidecar worker: %+v", p)
+	}
+
+	if _, err = c.NextIDs(0); err == nil {
+		t.Fatal("count 0 should be rejected")
+	}
+}
+
+func TestServer_remoteError(t *testing.T) {
+	_, path := startServer(t, failGen{err: common.LeaseConflictErr.WithTrueErr(errors.New("session expired"))})
+	c, err := Dial(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	_, err = c.NextID()
+	var re *RemoteError
+	if !errors.As(err, &re) || re.Code != common.LeaseConflictErr.Code {
+		t.Fatalf("NextID err: %v", err)
+	}
+	if !errors.Is(err, snowFlake.ErrLeaseLost) {
+		t.Fatalf("remote error should match by code: %v", err)
+	}
+	// 服务端错误不影响连接
+	if _, err = c.NextIDs(3); !errors.Is(err, common.LeaseConflictErr) {
+		t.Fatalf("second call: %v", err)
+	}
+}
+
+func TestListen_staleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sf.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Listen(path); err == nil {
+		t.Fatal("socket in use should be rejected")
+	}
+	// 模拟异常退出: 关闭 listener 但保留 socket 文件
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	if _, err = os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+	l, err = Listen(path)
+	if err != nil {
+		t.Fatalf("stale socket should be replaced: %v", err)
+	}
+	l.Close()
+
+	file := filepath.Join(t.TempDir(), "plain")
+	os.WriteFile(file, nil, 0o600)
+	if _, err = Listen(file); err == nil {
+		t.Fatal("regular file should not be removed")
+	}
+}
+
+func TestClient_reconnect(t *testing.T) {
+	sf, err := snowFlake.NewWorker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sf.Close(context.Background())
+	srv, path := startServer(t, sf)
+
+	c, err := Dial(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err = c.NextID(); err != nil {
+		t.Fatal(err)
+	}
+	if err = srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.NextID(); err == nil {
+		t.Fatal("NextID should fail after the sidecar stops")
+	}
+
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv2 := New(sf)
+	go srv2.Serve(l)
+	defer srv2.Shutdown(context.Background())
+	if _, err = c.NextID(); err != nil {
+		t.Fatalf("client should reconnect: %v", err)
+	}
+}
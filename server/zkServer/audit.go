@@ -0,0 +1,145 @@
+package zkServer
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samuel/go-zookeeper/zk"
+
+	"github.com/lypee/snowFlake/common"
+	"github.com/lypee/snowFlake/utils"
+)
+
+// AuditRecord 审计记录, 每次占用和释放workerId各写一条
+type AuditRecord struct {
+	Seq          string `json:"-"` // 顺序节点名, 如 rec-0000000001
+	Action       string `json:"action"`
+	WorkerId     int    `json:"workerId"`
+	DataCenterId int64  `json:"dataCenterId"`
+	Host         string `json:"host"`
+	Stamp        int64  `json:"stamp"` // 毫秒
+}
+
+// AuditQuery 审计记录查询条件, 零值字段不参与过滤
+type AuditQuery struct {
+	WorkerId *int
+	Host     string
+	Since    time.Time
+	Until    time.Time
+}
+
+func (q AuditQuery) match(r *AuditRecord) bool {
+	if q.WorkerId != nil && *q.WorkerId != r.WorkerId {
+		return false
+	}
+	if q.Host != "" && q.Host != r.Host {
+		return false
+	}
+	if !q.Since.IsZero() && r.Stamp < q.Since.UnixNano()/1e6 {
+		return false
+	}
+	if !q.Until.IsZero() && r.Stamp > q.Until.UnixNano()/1e6 {
+		return false
+	}
+	return true
+}
+
+// History 按workerId、主机和时间范围查询审计记录, 按发生顺序返回
+func (srv *ZkServer) History(q AuditQuery) ([]AuditRecord, error) {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	c, err := srv.getConn()
+	if err != nil {
+		return nil, err
+	}
+	records, err := srv.auditRecords(c)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]AuditRecord, 0, len(records))
+	for i := range records {
+		if q.match(&records[i]) {
+			res = append(res, records[i])
+		}
+	}
+	return res, nil
+}
+
+// CleanAudit 按保留策略删除旧的审计记录, 返回删除的数量
+func (srv *ZkServer) CleanAudit() (int, error) {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	c, err := srv.getConn()
+	if err != nil {
+		return 0, err
+	}
+	records, err := srv.auditRecords(c)
+	if err != nil {
+		return 0, err
+	}
+	deadline := int64(0)
+	if srv.opt.auditMaxAge > 0 {
+		deadline = time.Now().Add(-srv.opt.auditMaxAge).UnixNano() / 1e6
+	}
+	overflow := 0
+	if srv.opt.auditMaxRecords > 0 && len(records) > srv.opt.auditMaxRecords {
+		overflow = len(records) - srv.opt.auditMaxRecords
+	}
+	delNums := 0
+	for i, r := range records {
+		if i >= overflow && r.Stamp >= deadline {
+			// 记录按时间顺序排列, 之后的都需要保留
+			break
+		}
+		if err = c.Delete(utils.SpliceString(srv.opt.auditDirPath(), "/", r.Seq), -1); err != nil && err != zk.ErrNoNode {
+			return delNums, common.OpErr.WithTrueErr(err)
+		}
+		delNums++
+	}
+	return delNums, nil
+}
+
+// StartAuditCleaner 在后台定期清理审计记录, Shutdown 后停止
+func (srv *ZkServer) StartAuditCleaner(interval time.Duration) {
+	if srv.opt.auditMaxAge <= 0 && srv.opt.auditMaxRecords <= 0 {
+		return
+	}
+	srv.runEvery(interval, func() {
+		if _, err := srv.CleanAudit(); err != nil {
+			srv.reportErr(err)
+		}
+	})
+}
+
+// auditRecords 读取全部审计记录, 顺序节点名按序号排列即发生顺序
+func (srv *ZkServer) auditRecords(c zkConn) ([]AuditRecord, error) {
+	dir := srv.opt.auditDirPath()
+	children, _, err := c.Children(dir)
+	if err == zk.ErrNoNode {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, common.OpErr.WithTrueErr(err)
+	}
+	sort.Strings(children)
+	records := make([]AuditRecord, 0, len(children))
+	for _, child := range children {
+		if !strings.HasPrefix(child, auditNodePrefix) {
+			continue
+		}
+		data, _, err := c.Get(utils.SpliceString(dir, "/", child))
+		if err != nil {
+			continue
+		}
+		r := AuditRecord{Seq: child}
+		if json.Unmarshal(data, &r) != nil {
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
@@ -0,0 +1,65 @@
+package zkServer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestZkServer_History(t *testing.T) {
+	srvA, c := newFakeZkServer()
+	WithIdentity("pod-a")(srvA.opt)
+	srvB := newFakeZkServerOn(c)
+	WithIdentity("pod-b")(srvB.opt)
+
+	idA, err := srvA.GetWorkerId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = srvA.ReleaseWorkerId(idA); err != nil {
+		t.Fatal(err)
+	}
+	idB, err := srvB.GetWorkerId()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := srvA.History(AuditQuery{WorkerId: &idA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Action != auditAcquire || records[1].Action != auditRelease {
+		t.Fatalf("history of worker %d: %+v", idA, records)
+	}
+	records, _ = srvA.History(AuditQuery{Host: "pod-b"})
+	if len(records) != 1 || records[0].WorkerId != idB {
+		t.Fatalf("history of pod-b: %+v", records)
+	}
+	records, _ = srvA.History(AuditQuery{Since: time.Now().Add(time.Hour)})
+	if len(records) != 0 {
+		t.Fatalf("history in the future: %+v", records)
+	}
+}
+
+func TestZkServer_CleanAudit(t *testing.T) {
+	srv, c := newFakeZkServer()
+	WithAuditRetention(0, 2)(srv.opt)
+	for i := 0; i < 5; i++ {
+		if ok, err := srv.register(c, i); !ok || err != nil {
+			t.Fatalf("register %d: %v, %v", i, ok, err)
+		}
+	}
+	n, err := srv.CleanAudit()
+	if err != nil || n != 3 {
+		t.Fatalf("CleanAudit: %d, %v", n, err)
+	}
+	records, _ := srv.History(AuditQuery{})
+	if len(records) != 2 || records[0].WorkerId != 3 {
+		t.Fatalf("remaining: %+v", records)
+	}
+
+	WithAuditRetention(time.Nanosecond, 0)(srv.opt)
+	time.Sleep(2 * time.Millisecond)
+	if n, _ = srv.CleanAudit(); n != 2 {
+		t.Fatalf("CleanAudit by age: %d", n)
+	}
+}
@@ -0,0 +1,129 @@
+package zkServer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"time"
+
+	"github.com/samuel/go-zookeeper/zk"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+const (
+	casRetries    = 10 // 版本冲突时最多重试的次数
+	casBackoffMin = 2 * time.Millisecond
+	casBackoffMax = 200 * time.Millisecond
+)
+
+// counterValue 计数节点中保存的数据
+type counterValue struct {
+	Value int64 `json:"value"`
+}
+
+// AddCounter 把 key 的计数节点原子地增加 delta 并返回增加后的值, 节点不存在时从0开始创建
+// 计数节点为 <appRootPath>/_seq/<key>, key 经过 URL 转义后作为节点名, 可以包含 /
+func (srv *ZkServer) AddCounter(ctx context.Context, key string, delta int64) (int64, error) {
+	name := url.PathEscape(key)
+	if name == "" || name == "." || name == ".." {
+		return 0, common.NodeNameErr.WithTrueErr(fmt.Errorf("counter key %q", key))
+	}
+	var res counterValue
+	_, err := srv.casNode(ctx, srv.opt.counterPath(name), true, func(data []byte) ([]byte, error) {
+		res = counterValue{}
+		if data != nil {
+			if err := json.Unmarshal(data, &res); err != nil {
+				return nil, fmt.Errorf("counter %q: %w", key, err)
+			}
+		}
+		res.Value += delta
+		return json.Marshal(res)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return res.Value, nil
+}
+
+// casNode 读取 path 后按 update 计算的新数据以读到的版本写回(compare-and-set), 版本冲突时退避重试,
+// 重试次数用完后返回 common.OpErr(原因为 zk.ErrBadVersion)
+// 节点不存在时: create 为 true 则以 update(nil) 的结果创建, 否则原样返回 zk.ErrNoNode
+func (srv *ZkServer) casNode(ctx context.Context, path string, create bool, update func(data []byte) ([]byte, error)) ([]byte, error) {
+	backoff := casBackoffMin
+	for i := 0; ; i++ {
+		data, conflict, err := srv.casOnce(path, create, update)
+		if !conflict {
+			return data, err
+		}
+		if i >= casRetries {
+			return nil, common.OpErr.WithTrueErr(zk.ErrBadVersion)
+		}
+		// 随机化退避时间, 避免多个进程同时重试再次冲突
+		t := time.NewTimer(backoff/2 + time.Duration(rand.Int63n(int64(backoff))))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, common.OpErr.WithTrueErr(ctx.Err())
+		case <-t.C:
+		}
+		if backoff *= 2; backoff > casBackoffMax {
+			backoff = casBackoffMax
+		}
+	}
+}
+
+// casOnce 一次读-改-写, 版本冲突或并发创建时 conflict 为 true
+// 每次尝试单独持有 srv.lock, 退避期间不阻塞其他操作
+func (srv *ZkServer) casOnce(path string, create bool, update func(data []byte) ([]byte, error)) (data []byte, conflict bool, err error) {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	c, err := srv.getConn()
+	if err != nil {
+		return nil, false, err
+	}
+	old, stat, err := c.Get(path)
+	if err == zk.ErrNoNode {
+		if !create {
+			return nil, false, err
+		}
+		if data, err = update(nil); err != nil {
+			return nil, false, common.OpErr.WithTrueErr(err)
+		}
+		if _, err = srv.createFatherNode(c, path); err != nil {
+			return nil, false, err
+		}
+		_, err = c.Create(path, data, 0, srv.opt.acl())
+		if err == zk.ErrNodeExists {
+			return nil, true, nil
+		}
+		if err != nil {
+			return nil, false, common.OpErr.WithTrueErr(err)
+		}
+		return data, false, nil
+	}
+	if err != nil {
+		return nil, false, common.OpErr.WithTrueErr(err)
+	}
+	if data, err = update(old); err != nil {
+		return nil, false, common.OpErr.WithTrueErr(err)
+	}
+	_, err = c.Set(path, data, stat.Version)
+	if err == zk.ErrBadVersion {
+		return nil, true, nil
+	}
+	if err == zk.ErrNoNode {
+		if create {
+			// 读取后被删除, 重试时重新创建
+			return nil, true, nil
+		}
+		return nil, false, err
+	}
+	if err != nil {
+		return nil, false, common.OpErr.WithTrueErr(err)
+	}
+	return data, false, nil
+}
@@ -0,0 +1,74 @@
+package zkServer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+func TestZkServer_AddCounter(t *testing.T) {
+	srv, c := newFakeZkServer()
+	ctx := context.Background()
+	if _, err := srv.AddCounter(ctx, "", 1); !errors.Is(err, common.NodeNameErr) {
+		t.Fatalf("empty key: %v", err)
+	}
+	if _, err := srv.AddCounter(ctx, "..", 1); !errors.Is(err, common.NodeNameErr) {
+		t.Fatalf("key ..: %v", err)
+	}
+
+	if v, err := srv.AddCounter(ctx, "invoice/m1", 100); err != nil || v != 100 {
+		t.Fatalf("first add: %d %v", v, err)
+	}
+	if v, err := srv.AddCounter(ctx, "invoice/m1", 50); err != nil || v != 150 {
+		t.Fatalf("second add: %d %v", v, err)
+	}
+	if _, _, err := c.Get("/IDMaker/_seq/invoice%2Fm1"); err != nil {
+		t.Fatalf("counter node: %v", err)
+	}
+	if v, _ := srv.AddCounter(ctx, "invoice/m2", 1); v != 1 {
+		t.Fatalf("keys should be independent: %d", v)
+	}
+
+	// 版本冲突后重试成功
+	srv.conn = &racingConn{fakeConn: c, conflicts: 2}
+	if v, err := srv.AddCounter(ctx, "invoice/m1", 10); err != nil || v != 160 {
+		t.Fatalf("add after conflicts: %d %v", v, err)
+	}
+}
+
+func TestZkServer_AddCounter_concurrent(t *testing.T) {
+	c := newFakeConn()
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = map[int64]bool{}
+	)
+	// 多个进程同时创建并递增同一个计数节点
+	for i := 0; i < 4; i++ {
+		srv := newFakeZkServerOn(c)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				v, err := srv.AddCounter(context.Background(), "k", 1)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				if seen[v] {
+					t.Errorf("value %d returned twice", v)
+				}
+				seen[v] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 80 || !seen[80] {
+		t.Fatalf("values: %d", len(seen))
+	}
+}
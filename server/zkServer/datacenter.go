@@ -0,0 +1,92 @@
+package zkServer
+
+import (
+	"strings"
+
+	"github.com/samuel/go-zookeeper/zk"
+	"github.com/spf13/cast"
+
+	"github.com/lypee/snowFlake/common"
+	"github.com/lypee/snowFlake/utils"
+)
+
+// AllocDataCenterId 按数据中心名称分配数据中心ID, 同一名称总是得到同一个ID,
+// 之后的workerId分配都在该数据中心的子树下进行
+func (srv *ZkServer) AllocDataCenterId(name string) (int64, error) {
+	if name == "" {
+		return 0, common.NodeNameErr
+	}
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	c, err := srv.getConn()
+	if err != nil {
+		return 0, err
+	}
+	registered, err := srv.dataCenters(c)
+	if err != nil {
+		return 0, err
+	}
+	if id, ok := registered[name]; ok {
+		srv.opt.dataCenterId = id
+		return id, nil
+	}
+
+	used := make(map[int64]bool, len(registered))
+	for _, id := range registered {
+		used[id] = true
+	}
+	for id := int64(0); id <= common.MaxDataCenterID; id++ {
+		if used[id] {
+			continue
+		}
+		path := srv.opt.dataCenterPath(id)
+		if _, err = srv.createFatherNode(c, path); err != nil {
+			return 0, err
+		}
+		_, err = c.Create(path, []byte(name), 0, srv.opt.acl())
+		if err == zk.ErrNodeExists {
+			// 被其他数据中心抢先登记
+			continue
+		}
+		if err != nil {
+			return 0, common.OpErr.WithTrueErr(err)
+		}
+		// 同名数据中心并发登记时保留较小的ID
+		if registered, err = srv.dataCenters(c); err == nil && registered[name] != id {
+			_ = c.Delete(path, -1)
+			id = registered[name]
+		}
+		srv.log().Info("dataCenter registered", "name", name, "dataCenterId", id)
+		srv.opt.dataCenterId = id
+		return id, nil
+	}
+	return 0, common.DataCenterExhaustedErr
+}
+
+// dataCenters 已登记的数据中心, key为名称; 同名重复登记时取较小的ID
+func (srv *ZkServer) dataCenters(c zkConn) (map[string]int64, error) {
+	dir := utils.SpliceString(srv.opt.appRootPath(), "/", dataCenterDir)
+	children, _, err := c.Children(dir)
+	if err == zk.ErrNoNode {
+		return map[string]int64{}, nil
+	}
+	if err != nil {
+		return nil, common.OpErr.WithTrueErr(err)
+	}
+	res := make(map[string]int64, len(children))
+	for _, child := range children {
+		if !strings.HasPrefix(child, dataCenterPrefix) {
+			continue
+		}
+		id := cast.ToInt64(strings.TrimPrefix(child, dataCenterPrefix))
+		data, _, err := c.Get(utils.SpliceString(dir, "/", child))
+		if err != nil {
+			continue
+		}
+		if old, ok := res[string(data)]; !ok || id < old {
+			res[string(data)] = id
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,33 @@
+package zkServer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestZkServer_AllocDataCenterId(t *testing.T) {
+	srv, _ := newFakeZkServer()
+	sh, err := srv.AllocDataCenterId("sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bj, err := srv.AllocDataCenterId("bj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sh == bj {
+		t.Fatalf("different dataCenters got the same id %d", sh)
+	}
+	again, _ := srv.AllocDataCenterId("sh")
+	if again != sh {
+		t.Fatalf("dataCenter sh: %d, again: %d", sh, again)
+	}
+
+	id, err := srv.GetWorkerId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(srv.opt.workerPath(id), "/IDMaker/0/") {
+		t.Fatalf("worker path: %s", srv.opt.workerPath(id))
+	}
+}
@@ -0,0 +1,198 @@
+package zkServer
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/samuel/go-zookeeper/zk"
+)
+
+type fakeNode struct {
+	data    []byte
+	version int32
+	flags   int32
+	acl     []zk.ACL
+}
+
+// fakeConn 内存中的zk树, 用于不依赖真实zk的单元测试
+type fakeConn struct {
+	mu    sync.Mutex
+	nodes map[string]*fakeNode
+	seq   map[string]int
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		nodes: map[string]*fakeNode{"/": {}},
+		seq:   map[string]int{},
+	}
+}
+
+func newFakeZkServer() (*ZkServer, *fakeConn) {
+	c := newFakeConn()
+	return newFakeZkServerOn(c), c
+}
+
+// newFakeZkServerOn 共用同一棵zk树的另一个 ZkServer, 模拟其他主机
+func newFakeZkServerOn(c *fakeConn) *ZkServer {
+	opt := DefaultOpt()
+	opt.servers = []string{"fake"}
+	srv := NewZkServer(make(chan error, 3), opt)
+	srv.conn = c
+	return srv
+}
+
+func (c *fakeConn) stat(n *fakeNode) *zk.Stat {
+	return &zk.Stat{Version: n.version, DataLength: int32(len(n.data))}
+}
+
+func (c *fakeConn) Exists(p string) (bool, *zk.Stat, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.nodes[p]
+	if !ok {
+		return false, nil, nil
+	}
+	return true, c.stat(n), nil
+}
+
+func (c *fakeConn) Create(p string, data []byte, flags int32, acl []zk.ACL) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.nodes[path.Dir(p)]; !ok {
+		return "", zk.ErrNoNode
+	}
+	if flags&zk.FlagSequence != 0 {
+		parent := path.Dir(p)
+		p = fmt.Sprintf("%s%010d", p, c.seq[parent])
+		c.seq[parent]++
+	}
+	if _, ok := c.nodes[p]; ok {
+		return "", zk.ErrNodeExists
+	}
+	c.nodes[p] = &fakeNode{data: data, flags: flags, acl: acl}
+	return p, nil
+}
+
+func (c *fakeConn) Get(p string) ([]byte, *zk.Stat, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.nodes[p]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	return n.data, c.stat(n), nil
+}
+
+func (c *fakeConn) Set(p string, data []byte, version int32) (*zk.Stat, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.nodes[p]
+	if !ok {
+		return nil, zk.ErrNoNode
+	}
+	if version != -1 && version != n.version {
+		return nil, zk.ErrBadVersion
+	}
+	n.data = data
+	n.version++
+	return c.stat(n), nil
+}
+
+func (c *fakeConn) Delete(p string, version int32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.nodes[p]
+	if !ok {
+		return zk.ErrNoNode
+	}
+	if version != -1 && version != n.version {
+		return zk.ErrBadVersion
+	}
+	if len(c.children(p)) > 0 {
+		return zk.ErrNotEmpty
+	}
+	delete(c.nodes, p)
+	return nil
+}
+
+func (c *fakeConn) Children(p string) ([]string, *zk.Stat, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.nodes[p]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	return c.children(p), c.stat(n), nil
+}
+
+func (c *fakeConn) children(p string) []string {
+	prefix := strings.TrimSuffix(p, "/") + "/"
+	var res []string
+	for k := range c.nodes {
+		if k != p && strings.HasPrefix(k, prefix) && !strings.Contains(k[len(prefix):], "/") {
+			res = append(res, k[len(prefix):])
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (c *fakeConn) Close() {}
+
+// expire 模拟会话过期, 删除所有临时节点
+func (c *fakeConn) expire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, n := range c.nodes {
+		if n.flags&zk.FlagEphemeral != 0 {
+			delete(c.nodes, k)
+		}
+	}
+}
+
+// Multi 依次执行各操作, 任一失败时回滚全部修改
+func (c *fakeConn) Multi(ops ...interface{}) ([]zk.MultiResponse, error) {
+	c.mu.Lock()
+	nodes := make(map[string]*fakeNode, len(c.nodes))
+	for k, n := range c.nodes {
+		copied := *n
+		nodes[k] = &copied
+	}
+	seq := make(map[string]int, len(c.seq))
+	for k, v := range c.seq {
+		seq[k] = v
+	}
+	c.mu.Unlock()
+
+	res := make([]zk.MultiResponse, len(ops))
+	for i, op := range ops {
+		var err error
+		switch req := op.(type) {
+		case *zk.CreateRequest:
+			res[i].String, err = c.Create(req.Path, req.Data, req.Flags, req.Acl)
+		case *zk.SetDataRequest:
+			res[i].Stat, err = c.Set(req.Path, req.Data, req.Version)
+		case *zk.DeleteRequest:
+			err = c.Delete(req.Path, req.Version)
+		case *zk.CheckVersionRequest:
+			var stat *zk.Stat
+			if _, stat, err = c.Get(req.Path); err == nil && stat.Version != req.Version {
+				err = zk.ErrBadVersion
+			}
+		default:
+			err = zk.ErrAPIError
+		}
+		if err != nil {
+			res[i].Error = err
+			c.mu.Lock()
+			c.nodes, c.seq = nodes, seq
+			c.mu.Unlock()
+			return res, err
+		}
+	}
+	return res, nil
+}
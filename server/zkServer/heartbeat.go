@@ -0,0 +1,157 @@
+package zkServer
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/samuel/go-zookeeper/zk"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+// workerStamp 持久节点中保存的数据
+type workerStamp struct {
+	Host  string `json:"host"`
+	Stamp int64  `json:"stamp"` // 毫秒时间戳, 不小于该worker已发出ID的时间戳
+}
+
+// ReportStamp 将 workerId 最近使用的时间戳写入持久节点, 节点不存在时创建
+func (srv *ZkServer) ReportStamp(workerId int, stamp int64) error {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	c, err := srv.getConn()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(workerStamp{Host: srv.opt.identity, Stamp: stamp})
+	if err != nil {
+		return common.OpErr.WithTrueErr(err)
+	}
+	path := srv.opt.stampPath(workerId)
+	_, err = c.Set(path, data, -1)
+	if err == zk.ErrNoNode {
+		if _, err = srv.createFatherNode(c, path); err != nil {
+			return err
+		}
+		_, err = c.Create(path, data, 0, srv.opt.acl())
+	}
+	if err != nil {
+		return common.OpErr.WithTrueErr(err)
+	}
+	srv.lastReport = time.Now()
+	return nil
+}
+
+// LastReportTime 最近一次成功上报时间戳的时间, 可据此判断租约是否仍然有效
+func (srv *ZkServer) LastReportTime() time.Time {
+	srv.lock.RLock()
+	defer srv.lock.RUnlock()
+	return srv.lastReport
+}
+
+// GetLastStamp 读取 workerId 上一次上报的时间戳, 从未上报过时返回0
+func (srv *ZkServer) GetLastStamp(workerId int) (int64, error) {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	c, err := srv.getConn()
+	if err != nil {
+		return 0, err
+	}
+	ws, err := srv.readStamp(c, srv.opt.stampPath(workerId))
+	if err == zk.ErrNoNode {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return ws.Stamp, nil
+}
+
+// GetLiveStamps 返回除 excludeId 以外所有存活worker上报的时间戳, key为workerId
+func (srv *ZkServer) GetLiveStamps(excludeId int) (map[int]int64, error) {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	c, err := srv.getConn()
+	if err != nil {
+		return nil, err
+	}
+	children, _, err := c.Children(srv.opt.rootPath())
+	if err == zk.ErrNoNode {
+		return map[int]int64{}, nil
+	}
+	if err != nil {
+		return nil, common.OpErr.WithTrueErr(err)
+	}
+	stamps := make(map[int]int64, len(children))
+	for _, child := range children {
+		if !strings.HasPrefix(child, workerNodePrefix) {
+			continue
+		}
+		workerId, err := srv.genTrueWorkerIdByNodeName(child)
+		if err != nil || workerId == excludeId {
+			continue
+		}
+		ws, err := srv.readStamp(c, srv.opt.stampPath(workerId))
+		if err != nil {
+			// 刚注册还未上报
+			continue
+		}
+		stamps[workerId] = ws.Stamp
+	}
+	return stamps, nil
+}
+
+// StartHeartbeat 按 heartbeatInterval 定时上报 stampFn 返回的时间戳, Shutdown 后停止
+func (srv *ZkServer) StartHeartbeat(workerId int, stampFn func() int64) {
+	if err := srv.ReportStamp(workerId, stampFn()); err != nil {
+		srv.reportErr(err)
+	}
+	srv.runEvery(srv.opt.heartbeatInterval, func() {
+		if err := srv.ReportStamp(workerId, stampFn()); err != nil {
+			srv.reportErr(err)
+		}
+	})
+}
+
+// runEvery 在后台按 interval 周期执行 fn, Shutdown 后停止
+func (srv *ZkServer) runEvery(interval time.Duration, fn func()) {
+	srv.bgWg.Add(1)
+	go func() {
+		defer srv.bgWg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-srv.stopCh:
+				return
+			case <-ticker.C:
+				fn()
+			}
+		}
+	}()
+}
+
+func (srv *ZkServer) readStamp(c zkConn, path string) (*workerStamp, error) {
+	data, _, err := c.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	ws := &workerStamp{}
+	if err = json.Unmarshal(data, ws); err != nil {
+		return nil, common.OpErr.WithTrueErr(err)
+	}
+	return ws, nil
+}
+
+// reportErr 非阻塞地将错误投递到 errCh
+func (srv *ZkServer) reportErr(err error) {
+	srv.log().Warning("zkServer error", "err", err)
+	select {
+	case srv.errCh <- err:
+	default:
+	}
+}
@@ -0,0 +1,76 @@
+package zkServer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+func TestZkServer_ReportStamp(t *testing.T) {
+	srv, _ := newFakeZkServer()
+	stamp, err := srv.GetLastStamp(7)
+	if err != nil || stamp != 0 {
+		t.Fatalf("GetLastStamp before report: %d, %v", stamp, err)
+	}
+	if err = srv.ReportStamp(7, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err = srv.ReportStamp(7, 200); err != nil {
+		t.Fatal(err)
+	}
+	stamp, err = srv.GetLastStamp(7)
+	if err != nil || stamp != 200 {
+		t.Fatalf("GetLastStamp: %d, %v", stamp, err)
+	}
+}
+
+func TestZkServer_GetLiveStamps(t *testing.T) {
+	srv, c := newFakeZkServer()
+	id1, err := srv.GetWorkerId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id2, err := srv.GetWorkerId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.ReportStamp(id1, 100)
+	srv.ReportStamp(id2, 200)
+	// 已下线worker的持久节点不计入
+	srv.ReportStamp(int(common.MaxWorkerID)+1, 300)
+
+	stamps, err := srv.GetLiveStamps(id1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stamps) != 1 || stamps[id2] != 200 {
+		t.Fatalf("GetLiveStamps: %v", stamps)
+	}
+
+	c.expire()
+	stamps, _ = srv.GetLiveStamps(-1)
+	if len(stamps) != 0 {
+		t.Fatalf("GetLiveStamps after expire: %v", stamps)
+	}
+}
+
+func TestZkServer_StartHeartbeat(t *testing.T) {
+	srv, c := newFakeZkServer()
+	srv.opt.heartbeatInterval = 10 * time.Millisecond
+	var stamp int64
+	srv.StartHeartbeat(3, func() int64 {
+		stamp++
+		return stamp
+	})
+	time.Sleep(55 * time.Millisecond)
+	srv.Shutdown()
+
+	_, stat, err := c.Get(srv.opt.stampPath(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Version < 2 {
+		t.Fatalf("expected several reports, version: %d", stat.Version)
+	}
+}
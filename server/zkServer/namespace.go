@@ -0,0 +1,129 @@
+package zkServer
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/samuel/go-zookeeper/zk"
+
+	"github.com/lypee/snowFlake/common"
+	"github.com/lypee/snowFlake/utils"
+)
+
+const (
+	workerNodePrefix = "Id-"
+	stampDir         = "_stamp"
+	tombstoneDir     = "_tombstone"
+	metaDir          = "_meta"
+	auditDir         = "_audit"
+	auditNodePrefix  = "rec-"
+	dataCenterDir    = "_dc"
+	dataCenterPrefix = "dc-"
+	segmentDir       = "_segment"
+	counterDir       = "_seq"
+)
+
+// appRootPath 应用根路径, 配置了 appName 时为 /IDMaker/<appName>, 否则为 /IDMaker
+func (opt *connOpt) appRootPath() string {
+	if opt.appName == "" {
+		return common.WorkIdPath
+	}
+	return utils.SpliceString(common.WorkIdPath, "/", opt.appName)
+}
+
+// rootPath 命名空间根路径, 每个数据中心独立分配workerId: <appRootPath>/<dataCenterId>
+func (opt *connOpt) rootPath() string {
+	return utils.SpliceString(opt.appRootPath(), "/", strconv.FormatInt(opt.dataCenterId, 10))
+}
+
+// dataCenterPath 数据中心ID登记节点, 如 /IDMaker/app/_dc/dc-1, 节点数据为数据中心名称
+func (opt *connOpt) dataCenterPath(dataCenterId int64) string {
+	return utils.SpliceString(opt.appRootPath(), "/", dataCenterDir, "/", dataCenterPrefix, strconv.FormatInt(dataCenterId, 10))
+}
+
+// segmentPath 号段计数节点, 如 /IDMaker/app/_segment/order, 各数据中心共用
+func (opt *connOpt) segmentPath(tag string) string {
+	return utils.SpliceString(opt.appRootPath(), "/", segmentDir, "/", tag)
+}
+
+// counterPath 按key计数的节点, 如 /IDMaker/app/_seq/invoice%2Fm1, 各数据中心共用
+func (opt *connOpt) counterPath(name string) string {
+	return utils.SpliceString(opt.appRootPath(), "/", counterDir, "/", name)
+}
+
+// workerPathPrefix worker临时节点前缀, 如 /IDMaker/app/0/Id-
+func (opt *connOpt) workerPathPrefix() string {
+	return utils.SpliceString(opt.rootPath(), "/", workerNodePrefix)
+}
+
+// stampPathPrefix 时间戳持久节点前缀, 如 /IDMaker/app/0/_stamp/Id-
+func (opt *connOpt) stampPathPrefix() string {
+	return utils.SpliceString(opt.rootPath(), "/", stampDir, "/", workerNodePrefix)
+}
+
+// tombstoneDirPath 已释放workerId的墓碑节点目录, 如 /IDMaker/app/0/_tombstone
+func (opt *connOpt) tombstoneDirPath() string {
+	return utils.SpliceString(opt.rootPath(), "/", tombstoneDir)
+}
+
+func (opt *connOpt) tombstonePath(workerId int) string {
+	return utils.SpliceString(opt.tombstoneDirPath(), "/", workerNodePrefix, strconv.Itoa(workerId))
+}
+
+// metaPath worker元数据持久节点, 如 /IDMaker/app/0/_meta/Id-1, 记录最近一次持有者
+func (opt *connOpt) metaPath(workerId int) string {
+	return utils.SpliceString(opt.rootPath(), "/", metaDir, "/", workerNodePrefix, strconv.Itoa(workerId))
+}
+
+// auditDirPath 审计记录目录, 如 /IDMaker/app/_audit, 各数据中心共用
+func (opt *connOpt) auditDirPath() string {
+	return utils.SpliceString(opt.appRootPath(), "/", auditDir)
+}
+
+// auditPathPrefix 审计记录为顺序节点, 如 /IDMaker/app/_audit/rec-0000000001
+func (opt *connOpt) auditPathPrefix() string {
+	return utils.SpliceString(opt.auditDirPath(), "/", auditNodePrefix)
+}
+
+func (opt *connOpt) workerPath(workerId int) string {
+	return opt.workerPathPrefix() + strconv.Itoa(workerId)
+}
+
+func (opt *connOpt) stampPath(workerId int) string {
+	return opt.stampPathPrefix() + strconv.Itoa(workerId)
+}
+
+// acl 创建节点使用的ACL: 配置了digest认证时只有该用户拥有权限
+func (opt *connOpt) acl() []zk.ACL {
+	if opt.digestUser == "" {
+		return zk.WorldACL(zk.PermAll)
+	}
+	return zk.DigestACL(zk.PermAll, opt.digestUser, opt.digestPassword)
+}
+
+// aclFor 返回 path 对应的ACL
+// 多个应用共享的祖先节点(如 /IDMaker)只开放读和创建, 这样各应用可以在其下建立自己的命名空间,
+// 但没有删除其他应用节点的权限
+func (opt *connOpt) aclFor(path string) []zk.ACL {
+	if opt.digestUser != "" && opt.appName != "" && isAncestorOrSelf(path, common.WorkIdPath) {
+		return zk.WorldACL(zk.PermRead | zk.PermCreate)
+	}
+	return opt.acl()
+}
+
+// auth AddAuth 使用的认证信息
+func (opt *connOpt) auth() (scheme string, auth []byte, ok bool) {
+	if opt.digestUser == "" {
+		return "", nil, false
+	}
+	return "digest", []byte(opt.digestUser + ":" + opt.digestPassword), true
+}
+
+// WorkerPathPrefix 当前命名空间下worker节点的路径前缀
+func (srv *ZkServer) WorkerPathPrefix() string {
+	return srv.opt.workerPathPrefix()
+}
+
+func isAncestorOrSelf(path, target string) bool {
+	return path == target || strings.HasPrefix(target, strings.TrimSuffix(path, "/")+"/")
+}
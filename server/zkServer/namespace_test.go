@@ -0,0 +1,69 @@
+package zkServer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/samuel/go-zookeeper/zk"
+)
+
+func TestConnOpt_rootPath(t *testing.T) {
+	opt := DefaultOpt()
+	opt.appName = ""
+	if opt.workerPath(5) != "/IDMaker/0/Id-5" {
+		t.Fatalf("legacy worker path: %s", opt.workerPath(5))
+	}
+	WithAppName("orders")(opt)
+	WithDataCenterId(2)(opt)
+	if opt.workerPath(5) != "/IDMaker/orders/2/Id-5" {
+		t.Fatalf("worker path: %s", opt.workerPath(5))
+	}
+	if opt.stampPath(5) != "/IDMaker/orders/2/_stamp/Id-5" {
+		t.Fatalf("stamp path: %s", opt.stampPath(5))
+	}
+}
+
+func TestZkServer_digestACL(t *testing.T) {
+	srv, c := newFakeZkServer()
+	WithAppName("orders")(srv.opt)
+	WithDigestAuth("orders", "secret")(srv.opt)
+
+	id, err := srv.GetWorkerId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := zk.DigestACL(zk.PermAll, "orders", "secret")
+	node := c.nodes[srv.opt.workerPath(id)]
+	if node == nil || node.acl[0] != digest[0] {
+		t.Fatalf("worker node acl: %+v", node)
+	}
+	if !strings.HasPrefix(srv.opt.workerPath(id), "/IDMaker/orders/0/") {
+		t.Fatalf("worker path: %s", srv.opt.workerPath(id))
+	}
+	// 共享的 /IDMaker 不允许删除
+	shared := c.nodes["/IDMaker"].acl[0]
+	if shared.Scheme != "world" || shared.Perms&zk.PermDelete != 0 {
+		t.Fatalf("shared root acl: %+v", shared)
+	}
+	if app := c.nodes["/IDMaker/orders"].acl[0]; app != digest[0] {
+		t.Fatalf("app root acl: %+v", app)
+	}
+}
+
+func TestParseServers(t *testing.T) {
+	cases := []struct {
+		in   interface{}
+		want string
+	}{
+		{"a:2181;b:2181", "a:2181,b:2181"},
+		{[]interface{}{"a:2181", "b:2181"}, "a:2181,b:2181"},
+		{[]string{"a:2181"}, "a:2181"},
+		{"", ""},
+		{nil, ""},
+	}
+	for _, c := range cases {
+		if got := strings.Join(parseServers(c.in), ","); got != c.want {
+			t.Fatalf("parseServers(%v) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package zkServer
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/samuel/go-zookeeper/zk"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+const (
+	auditAcquire = "acquire"
+	auditRelease = "release"
+)
+
+// workerMeta worker节点及元数据节点中保存的持有者信息
+type workerMeta struct {
+	Host         string `json:"host"`
+	Pid          int    `json:"pid"`
+	WorkerId     int    `json:"workerId"`
+	DataCenterId int64  `json:"dataCenterId"`
+	RegisteredAt int64  `json:"registeredAt"` // 毫秒
+}
+
+// register 在一个 multi 事务中创建worker临时节点、写入元数据并追加审计记录, 三者要么都成功要么都失败
+// workId 已被其他进程抢先占用时返回 false
+func (srv *ZkServer) register(c zkConn, workId int) (bool, error) {
+	if err := srv.ensureParents(c); err != nil {
+		return false, err
+	}
+	now := time.Now().UnixNano() / 1e6
+	meta, err := json.Marshal(workerMeta{
+		Host:         srv.opt.identity,
+		Pid:          os.Getpid(),
+		WorkerId:     workId,
+		DataCenterId: srv.opt.dataCenterId,
+		RegisteredAt: now,
+	})
+	if err != nil {
+		return false, common.OpErr.WithTrueErr(err)
+	}
+	audit, err := srv.auditOp(auditAcquire, workId, now)
+	if err != nil {
+		return false, err
+	}
+
+	// 元数据节点带版本更新, 与并发注册同一ID的进程冲突时整个事务失败
+	metaPath := srv.opt.metaPath(workId)
+	var metaOp interface{} = &zk.CreateRequest{Path: metaPath, Data: meta, Acl: srv.opt.acl()}
+	exist, stat, err := c.Exists(metaPath)
+	if err != nil {
+		return false, common.OpErr.WithTrueErr(err)
+	}
+	if exist {
+		metaOp = &zk.SetDataRequest{Path: metaPath, Data: meta, Version: stat.Version}
+	}
+
+	_, err = c.Multi(
+		&zk.CreateRequest{Path: srv.opt.workerPath(workId), Data: meta, Acl: srv.opt.acl(), Flags: zk.FlagEphemeral},
+		metaOp,
+		audit,
+	)
+	switch err {
+	case nil:
+		return true, nil
+	case zk.ErrNodeExists, zk.ErrBadVersion:
+		return false, nil
+	default:
+		return false, common.OpErr.WithTrueErr(err)
+	}
+}
+
+// auditOp 追加审计记录的操作, 可以放入 multi 事务
+func (srv *ZkServer) auditOp(action string, workId int, stamp int64) (*zk.CreateRequest, error) {
+	data, err := json.Marshal(AuditRecord{
+		Action:       action,
+		WorkerId:     workId,
+		DataCenterId: srv.opt.dataCenterId,
+		Host:         srv.opt.identity,
+		Stamp:        stamp,
+	})
+	if err != nil {
+		return nil, common.OpErr.WithTrueErr(err)
+	}
+	return &zk.CreateRequest{Path: srv.opt.auditPathPrefix(), Data: data, Acl: srv.opt.acl(), Flags: zk.FlagSequence}, nil
+}
+
+// ensureParents 创建注册用到的各目录节点, 同一命名空间只需创建一次
+func (srv *ZkServer) ensureParents(c zkConn) error {
+	root := srv.opt.rootPath()
+	if srv.parentsReady == root {
+		return nil
+	}
+	for _, path := range []string{srv.opt.workerPathPrefix(), srv.opt.metaPath(0), srv.opt.auditPathPrefix(), srv.opt.tombstonePath(0)} {
+		if _, err := srv.createFatherNode(c, path); err != nil {
+			return err
+		}
+	}
+	srv.parentsReady = root
+	return nil
+}
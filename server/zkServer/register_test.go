@@ -0,0 +1,48 @@
+package zkServer
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestZkServer_register(t *testing.T) {
+	srv, c := newFakeZkServer()
+	ok, err := srv.register(c, 9)
+	if err != nil || !ok {
+		t.Fatalf("register: %v, %v", ok, err)
+	}
+	meta := &workerMeta{}
+	data, _, err := c.Get(srv.opt.metaPath(9))
+	if err != nil || json.Unmarshal(data, meta) != nil || meta.WorkerId != 9 {
+		t.Fatalf("meta: %s, %v", data, err)
+	}
+	children, _, _ := c.Children(srv.opt.auditDirPath())
+	if len(children) != 1 {
+		t.Fatalf("audit records: %v", children)
+	}
+
+	// 已被占用时事务整体失败, 不留下审计记录
+	ok, err = srv.register(c, 9)
+	if err != nil || ok {
+		t.Fatalf("register again: %v, %v", ok, err)
+	}
+	children, _, _ = c.Children(srv.opt.auditDirPath())
+	if len(children) != 1 {
+		t.Fatalf("audit records after failed register: %v", children)
+	}
+}
+
+func TestZkServer_createFatherNodeIdempotent(t *testing.T) {
+	srv, c := newFakeZkServer()
+	for i := 0; i < 2; i++ {
+		if _, err := srv.createFatherNode(c, "/IDMaker/app/0/Id-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if exist, _, _ := c.Exists("/IDMaker/app/0"); !exist {
+		t.Fatal("parent not created")
+	}
+	if exist, _, _ := c.Exists("/IDMaker/app/0/Id-1"); exist {
+		t.Fatal("leaf should not be created")
+	}
+}
@@ -0,0 +1,90 @@
+package zkServer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/samuel/go-zookeeper/zk"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+// segmentCounter 号段计数节点中保存的数据, 含义同 leaf_alloc 表的一行
+type segmentCounter struct {
+	MaxId int64 `json:"maxId"`
+	Step  int64 `json:"step"` // 基础步长
+}
+
+// CreateSegment 登记业务标识 tag 的号段计数节点, 初始 max_id 为 maxId, 基础步长为 step
+// 节点已存在时不做修改并返回 false
+func (srv *ZkServer) CreateSegment(tag string, maxId, step int64) (bool, error) {
+	if err := validSegmentTag(tag); err != nil {
+		return false, err
+	}
+	if step <= 0 {
+		return false, common.OpErr.WithTrueErr(fmt.Errorf("segment step %d must be positive", step))
+	}
+	data, err := json.Marshal(segmentCounter{MaxId: maxId, Step: step})
+	if err != nil {
+		return false, common.OpErr.WithTrueErr(err)
+	}
+
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	c, err := srv.getConn()
+	if err != nil {
+		return false, err
+	}
+	path := srv.opt.segmentPath(tag)
+	if _, err = srv.createFatherNode(c, path); err != nil {
+		return false, err
+	}
+	_, err = c.Create(path, data, 0, srv.opt.acl())
+	if err == zk.ErrNodeExists {
+		return false, nil
+	}
+	if err != nil {
+		return false, common.OpErr.WithTrueErr(err)
+	}
+	return true, nil
+}
+
+// AllocSegment 把 tag 计数节点的 max_id 增加 step(为0时使用节点中的基础步长), 返回增加后的 max_id 和基础步长
+// 以节点版本做 compare-and-set, 版本冲突时退避重试, 重试次数用完后返回 common.OpErr(原因为 zk.ErrBadVersion);
+// 节点不存在时返回 common.UnknownBizTagErr
+func (srv *ZkServer) AllocSegment(ctx context.Context, tag string, step int64) (maxId, baseStep int64, err error) {
+	if err = validSegmentTag(tag); err != nil {
+		return 0, 0, err
+	}
+	path := srv.opt.segmentPath(tag)
+	var sc segmentCounter
+	_, err = srv.casNode(ctx, path, false, func(data []byte) ([]byte, error) {
+		sc = segmentCounter{}
+		if err := json.Unmarshal(data, &sc); err != nil {
+			return nil, fmt.Errorf("segment %s: %w", path, err)
+		}
+		if step > 0 {
+			sc.MaxId += step
+		} else {
+			sc.MaxId += sc.Step
+		}
+		return json.Marshal(sc)
+	})
+	if err == zk.ErrNoNode {
+		return 0, 0, common.UnknownBizTagErr.WithTrueErr(fmt.Errorf("biz_tag %q", tag))
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return sc.MaxId, sc.Step, nil
+}
+
+// validSegmentTag tag 作为节点名, 不能为空或包含 /
+func validSegmentTag(tag string) error {
+	if tag == "" || strings.Contains(tag, "/") || tag == "." || tag == ".." {
+		return common.NodeNameErr.WithTrueErr(fmt.Errorf("biz_tag %q", tag))
+	}
+	return nil
+}
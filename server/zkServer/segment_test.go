@@ -0,0 +1,112 @@
+package zkServer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/samuel/go-zookeeper/zk"
+
+	"github.com/lypee/snowFlake/common"
+)
+
+// racingConn 每次 Set 之前先由"其他进程"修改一次节点, 使前 conflicts 次 compare-and-set 失败
+type racingConn struct {
+	*fakeConn
+	conflicts int
+}
+
+func (c *racingConn) Set(p string, data []byte, version int32) (*zk.Stat, error) {
+	if c.conflicts > 0 {
+		c.conflicts--
+		old, _, _ := c.fakeConn.Get(p)
+		c.fakeConn.Set(p, old, -1)
+	}
+	return c.fakeConn.Set(p, data, version)
+}
+
+func TestZkServer_AllocSegment(t *testing.T) {
+	srv, c := newFakeZkServer()
+	ctx := context.Background()
+	if _, _, err := srv.AllocSegment(ctx, "order", 0); !errors.Is(err, common.UnknownBizTagErr) {
+		t.Fatalf("unknown tag: %v", err)
+	}
+	if _, err := srv.CreateSegment("a/b", 1, 10); !errors.Is(err, common.NodeNameErr) {
+		t.Fatalf("invalid tag: %v", err)
+	}
+	if ok, err := srv.CreateSegment("order", 1, 100); !ok || err != nil {
+		t.Fatalf("CreateSegment: %v %v", ok, err)
+	}
+	if ok, _ := srv.CreateSegment("order", 1000, 5); ok {
+		t.Fatal("an existing segment should not be overwritten")
+	}
+	if _, _, err := c.Get("/IDMaker/_segment/order"); err != nil {
+		t.Fatalf("segment node: %v", err)
+	}
+
+	maxId, step, err := srv.AllocSegment(ctx, "order", 0)
+	if err != nil || maxId != 101 || step != 100 {
+		t.Fatalf("AllocSegment: %d %d %v", maxId, step, err)
+	}
+	maxId, step, err = srv.AllocSegment(ctx, "order", 400)
+	if err != nil || maxId != 501 || step != 100 {
+		t.Fatalf("AllocSegment with step: %d %d %v", maxId, step, err)
+	}
+
+	// 版本冲突后重试成功
+	srv.conn = &racingConn{fakeConn: c, conflicts: 3}
+	if maxId, _, err = srv.AllocSegment(ctx, "order", 0); err != nil || maxId != 601 {
+		t.Fatalf("AllocSegment after conflicts: %d %v", maxId, err)
+	}
+	// 重试次数用完
+	srv.conn = &racingConn{fakeConn: c, conflicts: casRetries + 1}
+	if _, _, err = srv.AllocSegment(ctx, "order", 0); !errors.Is(err, common.OpErr) || !errors.Is(err, zk.ErrBadVersion) {
+		t.Fatalf("AllocSegment with persistent conflicts: %v", err)
+	}
+	// 退避期间 ctx 取消
+	srv.conn = &racingConn{fakeConn: c, conflicts: casRetries + 1}
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, _, err = srv.AllocSegment(cctx, "order", 0); !errors.Is(err, context.Canceled) {
+		t.Fatalf("AllocSegment with a cancelled ctx: %v", err)
+	}
+}
+
+func TestZkServer_AllocSegment_concurrent(t *testing.T) {
+	c := newFakeConn()
+	srv := newFakeZkServerOn(c)
+	if _, err := srv.CreateSegment("order", 1, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = map[int64]bool{}
+	)
+	for i := 0; i < 4; i++ {
+		other := newFakeZkServerOn(c)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				maxId, _, err := other.AllocSegment(context.Background(), "order", 0)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				if seen[maxId] {
+					t.Errorf("segment ending at %d allocated twice", maxId)
+				}
+				seen[maxId] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 80 {
+		t.Fatalf("segments: %d", len(seen))
+	}
+}
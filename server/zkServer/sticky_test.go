@@ -0,0 +1,95 @@
+package zkServer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lypee/snowFlake/common"
+	"github.com/lypee/snowFlake/utils"
+)
+
+func TestZkServer_stickyWorkerId(t *testing.T) {
+	srvA, c := newFakeZkServer()
+	WithIdentity("pod-a")(srvA.opt)
+	preferred := int(utils.GenMurmur("pod-a") % uint32(common.MaxWorkerID+1))
+
+	id, err := srvA.GetWorkerId()
+	if err != nil || id != preferred {
+		t.Fatalf("first: %d, preferred: %d, err: %v", id, preferred, err)
+	}
+
+	// 首选ID被占用时探测相邻ID
+	srvB := NewZkServer(make(chan error, 1), srvA.opt)
+	srvB.conn = c
+	next, err := srvB.GetWorkerId()
+	if err != nil || next != (preferred+1)%int(common.MaxWorkerID+1) {
+		t.Fatalf("probe: %d, preferred: %d, err: %v", next, preferred, err)
+	}
+
+	// 本主机释放后立即重启, 首选ID仍在冷却期内, 使用下一个相邻ID
+	if err = srvA.ReleaseWorkerId(id); err != nil {
+		t.Fatal(err)
+	}
+	srvC := NewZkServer(make(chan error, 1), srvA.opt)
+	srvC.conn = c
+	if again, _ := srvC.GetWorkerId(); again != (preferred+2)%int(common.MaxWorkerID+1) {
+		t.Fatalf("restart in cool-down: %d, preferred: %d", again, preferred)
+	}
+
+	// 冷却期结束后重新拿到首选ID
+	srvA.opt.coolDown = time.Millisecond
+	time.Sleep(2 * time.Millisecond)
+	srvD := NewZkServer(make(chan error, 1), srvA.opt)
+	srvD.conn = c
+	if again, _ := srvD.GetWorkerId(); again != preferred {
+		t.Fatalf("restart after cool-down: %d, preferred: %d", again, preferred)
+	}
+}
+
+func TestZkServer_stickyRespectsCoolDown(t *testing.T) {
+	srvA, c := newFakeZkServer()
+	WithIdentity("pod-a")(srvA.opt)
+	WithProbeCount(0)(srvA.opt)
+	id, _ := srvA.GetWorkerId()
+	srvA.ReleaseWorkerId(id)
+
+	// 其他主机的首选ID恰好相同时需要等待冷却期
+	srvB := NewZkServer(make(chan error, 1), DefaultOpt())
+	WithIdentity("pod-b")(srvB.opt)
+	if cool, self := srvB.inCoolDown(c, id); !cool || self {
+		t.Fatalf("other host: cool %v, self %v", cool, self)
+	}
+	// 本主机同样需要等待, 同一主机上的另一个进程不能立即复用
+	if cool, self := srvA.inCoolDown(c, id); !cool || !self {
+		t.Fatalf("same host: cool %v, self %v", cool, self)
+	}
+	if ok, err := srvA.ClaimWorkerId(id); ok || err != nil {
+		t.Fatalf("claim by same host in cool-down: %v, %v", ok, err)
+	}
+	srvB.conn = c
+	WithServers([]string{"fake"})(srvB.opt)
+	if _, err := srvB.ClaimWorkerId(id); !errors.Is(err, common.LeaseConflictErr) {
+		t.Fatalf("claim by other host in cool-down: %v", err)
+	}
+}
+
+func TestZkServer_ClaimWorkerId(t *testing.T) {
+	srvA, c := newFakeZkServer()
+	WithIdentity("pod-a")(srvA.opt)
+	srvA.ReportStamp(42, 1)
+	ok, err := srvA.ClaimWorkerId(42)
+	if err != nil || !ok {
+		t.Fatalf("claim: %v, %v", ok, err)
+	}
+	// 本主机旧会话尚未过期
+	if ok, err = srvA.ClaimWorkerId(42); err != nil || ok {
+		t.Fatalf("claim again: %v, %v", ok, err)
+	}
+
+	srvB := newFakeZkServerOn(c)
+	WithIdentity("pod-b")(srvB.opt)
+	if _, err = srvB.ClaimWorkerId(42); !errors.Is(err, common.LeaseConflictErr) {
+		t.Fatalf("claim by other host: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package zkServer
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/samuel/go-zookeeper/zk"
+
+	"github.com/lypee/snowFlake/common"
+	"github.com/lypee/snowFlake/utils"
+)
+
+// tombstone 墓碑节点数据, 记录workerId的释放时间
+type tombstone struct {
+	Host         string `json:"host"`
+	ReleaseStamp int64  `json:"releaseStamp"` // 毫秒
+}
+
+// ReleaseWorkerId 释放 workerId: 在一个 multi 事务中写入墓碑、删除临时节点并追加审计记录,
+// 冷却期内该ID不会被再次分配
+func (srv *ZkServer) ReleaseWorkerId(workerId int) error {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	c, err := srv.getConn()
+	if err != nil {
+		return err
+	}
+	if err = srv.ensureParents(c); err != nil {
+		return err
+	}
+	now := time.Now().UnixNano() / 1e6
+	data, err := json.Marshal(tombstone{Host: srv.opt.identity, ReleaseStamp: now})
+	if err != nil {
+		return common.OpErr.WithTrueErr(err)
+	}
+	audit, err := srv.auditOp(auditRelease, workerId, now)
+	if err != nil {
+		return err
+	}
+
+	path := srv.opt.tombstonePath(workerId)
+	ops := []interface{}{audit}
+	if exist, _, err := c.Exists(path); err != nil {
+		return common.OpErr.WithTrueErr(err)
+	} else if exist {
+		ops = append(ops, &zk.SetDataRequest{Path: path, Data: data, Version: -1})
+	} else {
+		ops = append(ops, &zk.CreateRequest{Path: path, Data: data, Acl: srv.opt.acl()})
+	}
+	if exist, _, err := c.Exists(srv.opt.workerPath(workerId)); err != nil {
+		return common.OpErr.WithTrueErr(err)
+	} else if exist {
+		ops = append(ops, &zk.DeleteRequest{Path: srv.opt.workerPath(workerId), Version: -1})
+	}
+	if _, err = c.Multi(ops...); err != nil {
+		return common.OpErr.WithTrueErr(err)
+	}
+	srv.log().Info("workerId released", "workerId", workerId)
+	return nil
+}
+
+// inCoolDown workerId 是否处于冷却期, self 表示上一个持有者是否为本主机
+// 正常释放的ID以墓碑中的释放时间为准; 进程崩溃时没有墓碑, 以其最后上报的时间戳为准
+// 本主机的ID同样受冷却期限制: 同一主机上可能有多个进程, 上报的时间戳也可能落后于已发出的ID一个心跳周期
+func (srv *ZkServer) inCoolDown(c zkConn, workerId int) (cool, self bool) {
+	if srv.opt.coolDown <= 0 {
+		return false, false
+	}
+	deadline := time.Now().Add(-srv.opt.coolDown).UnixNano() / 1e6
+	if data, _, err := c.Get(srv.opt.tombstonePath(workerId)); err == nil {
+		ts := &tombstone{}
+		if json.Unmarshal(data, ts) == nil && ts.ReleaseStamp > deadline {
+			return true, srv.isSelf(ts.Host)
+		}
+	}
+	if ws, err := srv.readStamp(c, srv.opt.stampPath(workerId)); err == nil && ws.Stamp > deadline {
+		return true, srv.isSelf(ws.Host)
+	}
+	return false, false
+}
+
+// LeaseSafeWindow 最后一次确认持有租约后, 在此时长内其他主机不会占用同一workerId:
+// 临时节点最迟在会话超时后消失, 之后还要经过冷却期; 取 冷却时间 - 会话超时, 不大于0时表示没有安全窗口
+func (srv *ZkServer) LeaseSafeWindow() time.Duration {
+	return srv.opt.coolDown - srv.opt.sessionTimeout
+}
+
+func (srv *ZkServer) isSelf(host string) bool {
+	return srv.opt.identity != "" && host == srv.opt.identity
+}
+
+// CleanTombstones 删除已过冷却期的墓碑, 返回删除的数量
+func (srv *ZkServer) CleanTombstones() (int, error) {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	c, err := srv.getConn()
+	if err != nil {
+		return 0, err
+	}
+	dir := srv.opt.tombstoneDirPath()
+	children, _, err := c.Children(dir)
+	if err == zk.ErrNoNode {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, common.OpErr.WithTrueErr(err)
+	}
+	deadline := time.Now().Add(-srv.opt.coolDown).UnixNano() / 1e6
+	delNums := 0
+	for _, child := range children {
+		if !strings.HasPrefix(child, workerNodePrefix) {
+			continue
+		}
+		path := utils.SpliceString(dir, "/", child)
+		data, stat, err := c.Get(path)
+		if err != nil {
+			continue
+		}
+		ts := &tombstone{}
+		if json.Unmarshal(data, ts) == nil && ts.ReleaseStamp > deadline {
+			continue
+		}
+		// 带版本删除, 避免删掉刚被重新写入的墓碑
+		if err = c.Delete(path, stat.Version); err != nil {
+			continue
+		}
+		delNums++
+	}
+	return delNums, nil
+}
+
+// StartTombstoneCleaner 在后台按冷却时间周期清理过期墓碑, Shutdown 后停止
+func (srv *ZkServer) StartTombstoneCleaner() {
+	if srv.opt.coolDown <= 0 {
+		return
+	}
+	srv.runEvery(srv.opt.coolDown, func() {
+		if _, err := srv.CleanTombstones(); err != nil {
+			srv.reportErr(err)
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package zkServer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestZkServer_ReleaseWorkerId(t *testing.T) {
+	srv, c := newFakeZkServer()
+	id, err := srv.GetWorkerId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = srv.ReleaseWorkerId(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.nodes[srv.opt.workerPath(id)]; ok {
+		t.Fatal("worker node should be deleted")
+	}
+	if cool, _ := srv.inCoolDown(c, id); !cool {
+		t.Fatal("released workerId should be in cool-down")
+	}
+
+	srv.opt.coolDown = time.Millisecond
+	time.Sleep(2 * time.Millisecond)
+	if cool, _ := srv.inCoolDown(c, id); cool {
+		t.Fatal("cool-down should be over")
+	}
+	n, err := srv.CleanTombstones()
+	if err != nil || n != 1 {
+		t.Fatalf("CleanTombstones: %d, %v", n, err)
+	}
+}
+
+func TestZkServer_inCoolDownAfterCrash(t *testing.T) {
+	srv, c := newFakeZkServer()
+	id, err := srv.GetWorkerId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.ReportStamp(id, time.Now().UnixNano()/1e6)
+	c.expire()
+	// 没有墓碑, 以最后上报的时间戳判断
+	if cool, _ := srv.inCoolDown(c, id); !cool {
+		t.Fatal("crashed workerId should be in cool-down")
+	}
+}
@@ -1,7 +1,7 @@
 package zkServer
 
 import (
-	"strconv"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -20,22 +20,72 @@ import (
 )
 
 type connOpt struct {
-	addr           string
-	readTimeout    time.Duration
-	writeTimeout   time.Duration
-	sessionTimeout time.Duration
-	servers        []string
+	addr              string
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	sessionTimeout    time.Duration
+	heartbeatInterval time.Duration // 上报时间戳的间隔
+	servers           []string
+	appName           string // 应用名, 用于隔离不同应用的ID空间
+	dataCenterId      int64
+	digestUser        string // digest 认证, 为空时使用 world ACL
+	digestPassword    string
+	coolDown          time.Duration // workerId 释放后多久才能被再次分配
+	identity          string        // 主机标识, 用于计算首选workerId
+	probeCount        int           // 首选ID被占用时向后探测的个数
+	auditMaxAge       time.Duration // 审计记录保留时长, 0表示不按时间清理
+	auditMaxRecords   int           // 审计记录最多保留条数, 0表示不限
+	logger            base.Logger   // 为空时使用 base.DLogger
 }
 
 func DefaultOpt() *connOpt {
-	servers := viper.Get("Zookeeper.servers")
-	serverList := strings.Split(servers.(string), ";")
+	serverList := parseServers(viper.Get("Zookeeper.servers"))
+	coolDown := viper.GetDuration("Zookeeper.CoolDown")
+	if coolDown <= 0 {
+		coolDown = 10 * time.Second
+	}
+	auditMaxAge := viper.GetDuration("Zookeeper.Audit.MaxAge")
+	if auditMaxAge <= 0 {
+		auditMaxAge = 30 * 24 * time.Hour
+	}
 	return &connOpt{
-		readTimeout:    3 * time.Second,
-		writeTimeout:   3 * time.Second,
-		sessionTimeout: 3 * time.Second,
-		servers:        serverList,
+		readTimeout:       3 * time.Second,
+		writeTimeout:      3 * time.Second,
+		sessionTimeout:    3 * time.Second,
+		heartbeatInterval: 3 * time.Second,
+		servers:           serverList,
+		appName:           viper.GetString("App.Name"),
+		digestUser:        viper.GetString("Zookeeper.Digest.User"),
+		digestPassword:    viper.GetString("Zookeeper.Digest.Password"),
+		coolDown:          coolDown,
+		identity:          defaultIdentity(),
+		probeCount:        8,
+		auditMaxAge:       auditMaxAge,
+		auditMaxRecords:   viper.GetInt("Zookeeper.Audit.MaxRecords"),
+	}
+}
+
+// parseServers 兼容 yaml 列表和 "host1;host2" 形式的字符串, 去掉空地址
+func parseServers(v interface{}) []string {
+	raw := cast.ToStringSlice(v)
+	servers := make([]string, 0, len(raw))
+	for _, s := range raw {
+		for _, host := range strings.Split(s, ";") {
+			if host = strings.TrimSpace(host); host != "" {
+				servers = append(servers, host)
+			}
+		}
 	}
+	return servers
+}
+
+// defaultIdentity 主机标识: 优先使用 POD_NAME, 其次为主机名
+func defaultIdentity() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	host, _ := os.Hostname()
+	return host
 }
 
 func WithAddr(addr string) ConnOptFunc {
@@ -68,19 +118,126 @@ func WithSessionTimeout(d time.Duration) ConnOptFunc {
 	}
 }
 
+func WithHeartbeatInterval(d time.Duration) ConnOptFunc {
+	return func(opt *connOpt) {
+		opt.heartbeatInterval = d
+	}
+}
+
+// WithAppName 按应用隔离命名空间, 节点创建在 /IDMaker/<name>/<dataCenterId> 下
+func WithAppName(name string) ConnOptFunc {
+	return func(opt *connOpt) {
+		opt.appName = name
+	}
+}
+
+func WithDataCenterId(id int64) ConnOptFunc {
+	return func(opt *connOpt) {
+		opt.dataCenterId = id
+	}
+}
+
+// WithCoolDown workerId 释放后的冷却时间, 冷却期内不会被再次分配
+func WithCoolDown(d time.Duration) ConnOptFunc {
+	return func(opt *connOpt) {
+		opt.coolDown = d
+	}
+}
+
+// WithIdentity 指定主机标识, 为空时不使用首选ID
+func WithIdentity(identity string) ConnOptFunc {
+	return func(opt *connOpt) {
+		opt.identity = identity
+	}
+}
+
+// WithLogger 指定该 ZkServer 使用的 Logger, 为空时使用全局的 base.DLogger
+func WithLogger(l base.Logger) ConnOptFunc {
+	return func(opt *connOpt) {
+		opt.logger = l
+	}
+}
+
+func WithProbeCount(n int) ConnOptFunc {
+	return func(opt *connOpt) {
+		opt.probeCount = n
+	}
+}
+
+// WithAuditRetention 审计记录的保留策略, 超过 maxAge 或超出 maxRecords 条的旧记录会被后台清理
+func WithAuditRetention(maxAge time.Duration, maxRecords int) ConnOptFunc {
+	return func(opt *connOpt) {
+		opt.auditMaxAge = maxAge
+		opt.auditMaxRecords = maxRecords
+	}
+}
+
+// WithDigestAuth 使用 digest 认证, 创建的节点仅该用户可操作
+func WithDigestAuth(user, password string) ConnOptFunc {
+	return func(opt *connOpt) {
+		opt.digestUser = user
+		opt.digestPassword = password
+	}
+}
+
 type ConnOptFunc func(opt *connOpt)
 
+// zkConn ZkServer 用到的连接方法, *zk.Conn 实现了该接口
+type zkConn interface {
+	Exists(path string) (bool, *zk.Stat, error)
+	Create(path string, data []byte, flags int32, acl []zk.ACL) (string, error)
+	Get(path string) ([]byte, *zk.Stat, error)
+	Set(path string, data []byte, version int32) (*zk.Stat, error)
+	Delete(path string, version int32) error
+	Children(path string) ([]string, *zk.Stat, error)
+	Multi(ops ...interface{}) ([]zk.MultiResponse, error)
+	Close()
+}
+
 type ZkServer struct {
-	lock  sync.RWMutex
-	errCh chan error
-	opt   *connOpt
+	lock     sync.RWMutex
+	errCh    chan error
+	opt      *connOpt
+	conn     zkConn
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	bgWg     sync.WaitGroup // 心跳、清理等后台协程
+	// lastReport 最近一次成功上报时间戳的时间
+	lastReport time.Time
+	// parentsReady 已创建父节点的命名空间根路径
+	parentsReady string
 }
 
 func NewZkServer(errCh chan error, opt *connOpt) *ZkServer {
 	return &ZkServer{
-		opt:   opt,
-		errCh: errCh,
+		opt:    opt,
+		errCh:  errCh,
+		stopCh: make(chan struct{}),
+	}
+}
+
+func (srv *ZkServer) log() base.Logger {
+	return base.OrDefault(srv.opt.logger)
+}
+
+// getConn 获取长连接, 临时节点的生命周期与该连接一致; 调用方需持有 srv.lock
+func (srv *ZkServer) getConn() (zkConn, error) {
+	if srv.conn != nil {
+		return srv.conn, nil
+	}
+	c, _, err := zk.Connect(srv.opt.servers, srv.opt.sessionTimeout)
+	if err != nil {
+		srv.log().WarningF("zk.Connect-err:[%+v]", err)
+		return nil, common.StartConnErr.WithTrueErr(err)
+	}
+	if scheme, auth, ok := srv.opt.auth(); ok {
+		if err = c.AddAuth(scheme, auth); err != nil {
+			c.Close()
+			return nil, common.StartConnErr.WithTrueErr(err)
+		}
 	}
+	srv.conn = c
+	return c, nil
 }
 
 // GetWorkerIdWithPool -
@@ -94,25 +251,25 @@ func (srv *ZkServer) GetWorkerIdWithPool() (id int, err error) {
 		var exist bool
 		timeNow := time.Now()
 		for i := 0; i < int(common.MaxWorkerID/2); i++ {
-			base.InfoF("retry: %d times", i)
+			srv.log().InfoF("retry: %d times", i)
 			workId := utils.RandomNum(0, int(common.MaxWorkerID))
-			path := common.WorkIdPathPrefix + strconv.Itoa(workId)
+			path := srv.opt.workerPath(workId)
 			exist, _, err = c.Exists(path)
 			if err != nil {
-				base.ErrorF("path %v not exist", path)
+				srv.log().ErrorF("path %v not exist", path)
 				return 0, err
 			}
 			if exist {
-				base.InfoF("path [%+v] exist", path)
+				srv.log().InfoF("path [%+v] exist", path)
 				continue
 			}
-			_, err = c.Create(path, utils.Int64ToBytes(timeNow.Unix()), 0, zk.WorldACL(zk.PermAll))
+			_, err = c.Create(path, utils.Int64ToBytes(timeNow.Unix()), 0, srv.opt.acl())
 			if err != nil {
-				base.ErrorF("set path: %v fail", path)
+				srv.log().ErrorF("set path: %v fail", path)
 				return 0, err
 			}
 
-			base.InfoF("set path: %v success", path)
+			srv.log().InfoF("set path: %v success", path)
 			return workId, nil
 		}
 	}
@@ -121,6 +278,8 @@ func (srv *ZkServer) GetWorkerIdWithPool() (id int, err error) {
 }
 
 // GetWorkerId -
+// 先尝试由主机标识哈希得到的首选ID及其后 probeCount 个相邻ID, 使同一主机重启后尽量拿到相同的workerId,
+// 都不可用时再随机分配; 首选ID仍在冷却期内(如快速重启)时同样跳过
 func (srv *ZkServer) GetWorkerId() (id int, err error) {
 	if len(srv.opt.servers) < 1 {
 		return 0, common.ServersErr
@@ -128,79 +287,105 @@ func (srv *ZkServer) GetWorkerId() (id int, err error) {
 	srv.lock.Lock()
 	defer srv.lock.Unlock()
 
-	servers := srv.opt.servers
-	sessionTimeout := srv.opt.sessionTimeout
-
-	c, _, err := zk.Connect(servers, sessionTimeout)
+	c, err := srv.getConn()
 	if err != nil {
-		base.ErrorF("zk.Connect-err:[%+v]", err)
-		return 0, common.StartConnErr.WithTrueErr(err)
+		return 0, err
 	}
-	var exist, createdFatherNode bool
 
-	for i := 0; i < int(common.MaxWorkerID/2); i++ {
-		base.InfoF("retry: %d times", i)
-		workId := utils.RandomNum(0, int(common.MaxWorkerID))
-		path := common.WorkIdPathPrefix + strconv.Itoa(workId)
-		// check path valid
-		if valid, err := srv.validatePath(path, false); !valid || err != nil {
-			base.ErrorF("validatePath-fail:[%+v]", path, err)
-		}
-		exist, _, err = c.Exists(path) // todo handle father node if not exist
+	for _, workId := range srv.preferredIds() {
+		ok, err := srv.tryCreateWorkerNode(c, workId)
 		if err != nil {
-			base.ErrorF("c.Exist-err:[%+v]", err, path)
 			return 0, err
 		}
-		if exist {
-			// try create father node
-			base.InfoF("path %v exist", path)
-			continue
-		}
-		if !createdFatherNode {
-			_, err = srv.createFatherNode(path)
-			if err != nil {
-				base.InfoF("srv.createFatherNode-err:[%+v]", err, path)
-			}
-			createdFatherNode = true
+		if ok {
+			return workId, nil
 		}
-		//str, err := c.CreateProtectedEphemeralSequential(path, []byte{}, zk.WorldACL(zk.PermAll))
-		resPath, err := c.Create(path, []byte{}, zk.FlagEphemeral, zk.WorldACL(zk.PermAll))
+	}
+	for i := 0; i < int(common.MaxWorkerID/2); i++ {
+		srv.log().InfoF("retry: %d times", i)
+		workId := utils.RandomNum(0, int(common.MaxWorkerID))
+		ok, err := srv.tryCreateWorkerNode(c, workId)
 		if err != nil {
-			base.ErrorF("set path: %v fail", path)
 			return 0, err
 		}
-		base.InfoF("set path: [%+v] success", resPath, path, workId)
-		return workId, nil
+		if ok {
+			return workId, nil
+		}
 	}
 
-	return 0, common.ConnErr
+	return 0, common.WorkerIdExhaustedErr
 }
 
-func (srv *ZkServer) RemoveAllNode(basePath string) (bool, error) {
+// ClaimWorkerId 重新占用指定的 workerId, 用于zk不可用时以本地缓存的workerId启动后恢复租约
+// 该ID仍被本主机之前的会话占用或处于本主机释放后的冷却期时返回 false, 稍后重试即可; 已被其他主机占用时返回 LeaseConflictErr
+func (srv *ZkServer) ClaimWorkerId(workerId int) (bool, error) {
+	if len(srv.opt.servers) < 1 {
+		return false, common.ServersErr
+	}
 	srv.lock.Lock()
 	defer srv.lock.Unlock()
 
-	conn := pool.ClientConnPool.Get()
-	if c, ok := conn.(*zk.Conn); ok {
-		cds, _, err := c.Children(basePath)
-		if err != nil {
-			return false, common.OpErr.WithTrueErr(err)
+	c, err := srv.getConn()
+	if err != nil {
+		return false, err
+	}
+	if ws, err := srv.readStamp(c, srv.opt.stampPath(workerId)); err == nil && !srv.isSelf(ws.Host) {
+		if exist, _, _ := c.Exists(srv.opt.workerPath(workerId)); exist {
+			return false, common.LeaseConflictErr
 		}
-		delNums := 0
-		var path string
-		for i := 0; i < len(cds); i++ {
-			path = utils.SpliceString(basePath, "/", cds[i])
-			base.InfoF("completePath: %s", path)
-			err = c.Delete(path, -1)
-			if err != nil {
-				base.InfoF("c.Delete-err:[%+v]", err)
-				continue
-			}
-			delNums++
+	}
+	if cool, self := srv.inCoolDown(c, workerId); cool {
+		if self {
+			return false, nil
 		}
-		base.InfoF("delete.Nums:[%d]", delNums)
+		return false, common.LeaseConflictErr
 	}
-	return false, common.ConnErr
+	return srv.tryCreateWorkerNode(c, workerId)
+}
+
+// preferredIds 首选ID: GenMurmur(identity) mod (MaxWorkerID+1), 以及其后的 probeCount 个相邻ID
+func (srv *ZkServer) preferredIds() []int {
+	if srv.opt.identity == "" {
+		return nil
+	}
+	size := uint32(common.MaxWorkerID + 1)
+	preferred := utils.GenMurmur(srv.opt.identity) % size
+	ids := make([]int, 0, srv.opt.probeCount+1)
+	for i := 0; i <= srv.opt.probeCount; i++ {
+		ids = append(ids, int((preferred+uint32(i))%size))
+	}
+	return ids
+}
+
+// tryCreateWorkerNode 尝试占用 workId, 已被占用或处于冷却期时返回false
+func (srv *ZkServer) tryCreateWorkerNode(c zkConn, workId int) (bool, error) {
+	path := srv.opt.workerPath(workId)
+	// check path valid
+	if valid, err := srv.validatePath(path, false); !valid || err != nil {
+		srv.log().Error("validatePath fail", "path", path, "err", err)
+	}
+	exist, _, err := c.Exists(path)
+	if err != nil {
+		srv.log().Error("c.Exists fail", "path", path, "err", err)
+		return false, err
+	}
+	if exist {
+		srv.log().InfoF("path %v exist", path)
+		return false, nil
+	}
+	if cool, _ := srv.inCoolDown(c, workId); cool {
+		srv.log().Info("workerId in cool-down", "workerId", workId)
+		return false, nil
+	}
+	ok, err := srv.register(c, workId)
+	if err != nil {
+		srv.log().WarningF("register path: %v fail: %+v", path, err)
+		return false, err
+	}
+	if ok {
+		srv.log().Info("worker node registered", "workerId", workId, "path", path)
+	}
+	return ok, nil
 }
 
 // RemoveNode 删除单个节点
@@ -208,25 +393,36 @@ func (srv *ZkServer) RemoveNode(basePath, nodePath string) (success bool, err er
 	srv.lock.Lock()
 	defer srv.lock.Unlock()
 
-	conn := pool.ClientConnPool.Get()
-	if c, ok := conn.(*zk.Conn); ok {
+	if c, err := srv.getConn(); err == nil {
 		path := utils.SpliceString(basePath, nodePath)
-		base.InfoF("completePath: %s", path)
+		srv.log().InfoF("completePath: %s", path)
 		err = c.Delete(path, -1)
 		if err != nil {
-			base.ErrorF("c.Delete-err:[%+v]", err, path)
+			srv.log().Error("c.Delete fail", "path", path, "err", err)
 			return false, err
 		}
-		base.InfoF("c.Delete-success:[%+v]", path)
+		srv.log().InfoF("c.Delete-success:[%+v]", path)
 		return true, nil
 	}
 
 	return false, common.ConnErr
 }
 
+// Shutdown 停止后台协程并关闭连接, 本会话创建的临时节点随之删除, 不影响其他worker的节点
+// 需要立即释放workerId并写入墓碑时, 先调用 ReleaseWorkerId
 func (srv *ZkServer) Shutdown() {
-	srv.RemoveAllNode(common.WorkIdPath)
-	close(srv.errCh)
+	srv.stopOnce.Do(func() {
+		close(srv.stopCh)
+		srv.bgWg.Wait()
+
+		srv.lock.Lock()
+		if srv.conn != nil {
+			srv.conn.Close()
+			srv.conn = nil
+		}
+		srv.lock.Unlock()
+		close(srv.errCh)
+	})
 }
 
 // todo watch and delete node
@@ -244,38 +440,24 @@ func (srv *ZkServer) Shutdown() {
 //
 //}
 
-// 创建父节点
-func (srv *ZkServer) createFatherNode(path string) (success bool, err error) {
+// createFatherNode 幂等地创建 path 的所有父节点, 已存在的节点视为成功
+func (srv *ZkServer) createFatherNode(c zkConn, path string) (success bool, err error) {
 	paths := strings.Split(path, "/")
 	if len(paths) < 2 {
 		return false, common.PathLengthErr
 	}
 
 	var tmpPath string
-	servers := srv.opt.servers
-	sessionTimeout := srv.opt.sessionTimeout
-
-	c, _, err := zk.Connect(servers, sessionTimeout)
-	if err != nil {
-		base.ErrorF("zk.Connect-err:[%+v]", err)
-		return false, common.StartConnErr.WithTrueErr(err)
-	}
-
 	for i := 0; i < len(paths)-1; i++ {
 		if paths[i] == "" {
 			continue
 		}
 		tmpPath = utils.SpliceString(tmpPath, "/", paths[i])
-		_, err = c.Create(tmpPath, []byte{}, 0, zk.WorldACL(zk.PermAll))
-		if err != nil {
-			base.InfoF("create-err:[%+v] ", err, tmpPath)
-			continue
+		_, err = c.Create(tmpPath, []byte{}, 0, srv.opt.aclFor(tmpPath))
+		if err != nil && err != zk.ErrNodeExists {
+			return false, common.OpErr.WithTrueErr(err)
 		}
-		base.InfoF("createPath-success:[%+v]", tmpPath)
-
 	}
-	base.InfoF("createFatherNode-success:[%+v]", path)
-
 	return true, nil
 }
 
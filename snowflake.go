@@ -2,8 +2,7 @@ package snowFlake
 
 import (
 	"errors"
-	"os"
-	"os/signal"
+	"fmt"
 	"sync"
 	"time"
 
@@ -22,8 +21,43 @@ type SfWorker struct {
 	lastStamp    int64 // 记录上一次ID的时间戳
 	workerID     int64 // 该节点的ID
 	dataCenterID int64 // 该节点的 数据中心ID
-	sequence     int64 // 当前毫秒已经生成的ID序列号(从0 开始累加) 1毫秒内最多生成4096个ID
+	sequence     int64 // 当前毫秒已经生成的ID序列号(从0 开始累加) 1毫秒内最多生成1024个ID
 	ServerType   common.ServerType
+	epoch        int64         // 起始时间戳(毫秒)
+	maxRollback  time.Duration // 可容忍的时钟回拨, 由 mu 保护
+
+	degraded  bool  // zk不可用, 以本地缓存的租约运行
+	leaseLost bool  // 缓存的workerId已被其他主机占用, 拒绝发号
+	leasedAt  int64 // 最近一次确认持有租约的时间(毫秒)
+	hwm       *highWaterMark
+	stopCh    chan struct{}
+	// leaseCachePath 本地租约缓存文件, 为空表示未启用
+	leaseCachePath string
+	logger         base.Logger
+	unwatch        func() // 取消订阅配置变更
+
+	allocator    Allocator // 租约模式下的 workerId 分配器
+	lease        Lease     // 当前持有的租约, 由 mu 保护
+	leaseExpires int64     // 本地计算的租约(或降级时缓存的租约)到期时间(毫秒), 到期后拒绝发号, 0表示不限
+
+	closeMu   sync.RWMutex // NextID 持有读锁, Close 持有写锁以等待进行中的调用
+	closed    bool
+	initErr   error // NewSfWorker 启动失败的原因, 不为空时 closed 为 true
+
+	clock func() int64 // 当前时间(毫秒), 为空时取系统时间; 测试中用于冻结时钟
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// WorkerStatus worker的运行状态
+type WorkerStatus struct {
+	WorkerID     int64 `json:"workerId"`
+	DataCenterID int64 `json:"dataCenterId"`
+	LastStamp    int64 `json:"lastStamp"`
+	Degraded     bool  `json:"degraded"`
+	LeaseLost    bool  `json:"leaseLost"`
+	LeasedAt     int64 `json:"leasedAt"`
+	Closed       bool  `json:"closed"`
 }
 
 type InternalSrv struct {
@@ -34,25 +68,263 @@ var (
 	wg sync.WaitGroup
 )
 
+// maxSequenceWait 序列号用完后等待下一毫秒的最长时间
+const maxSequenceWait = 10 * time.Millisecond
+
+type workerOpt struct {
+	connOfs        []zkServer.ConnOptFunc
+	center         string        // workerId 分配器, zk 或空
+	maxClockSkew   time.Duration // 与其他存活worker平均时间的最大允许偏差
+	maxRollback    time.Duration // 可容忍的时钟回拨, 不超过该值时等待时钟追上
+	epoch          int64         // 起始时间戳(毫秒)
+	dataCenterID   int64         // 小于0表示未指定
+	dataCenterName string        // 未指定 dataCenterID 时按名称由分配器分配
+
+	leaseCachePath     string        // 本地租约缓存文件, 为空时不启用降级模式
+	leaseMaxStaleness  time.Duration // 缓存的租约最长可用多久
+	leaseCacheInterval time.Duration
+
+	hwmPath    string        // 时间戳上界文件, 为空时不启用
+	hwmReserve time.Duration // 每次预留的时间
+
+	logger      base.Logger // 为空时使用 base.DLogger
+	watchConfig bool        // 订阅配置变更
+	allocator   Allocator   // 不为空时从租约服务获取 workerId, 忽略 center
+}
+
+func defaultWorkerOpt() *workerOpt {
+	return &workerOpt{
+		center:             viper.GetString("Center.Name"),
+		maxClockSkew:       5 * time.Second,
+		epoch:              common.Twepoch,
+		dataCenterID:       -1,
+		leaseCacheInterval: time.Second,
+	}
+}
+
+type WorkerOptFunc func(opt *workerOpt)
+
+// WithConnOpts 透传给 zkServer 的连接参数
+func WithConnOpts(ofs ...zkServer.ConnOptFunc) WorkerOptFunc {
+	return func(opt *workerOpt) {
+		opt.connOfs = append(opt.connOfs, ofs...)
+	}
+}
+
+// WithMaxClockSkew 本机时间与其他存活worker上报时间的平均值相差超过 d 时拒绝启动
+func WithMaxClockSkew(d time.Duration) WorkerOptFunc {
+	return func(opt *workerOpt) {
+		opt.maxClockSkew = d
+	}
+}
+
+// WithMaxRollback 时钟回拨不超过 d 时等待时钟追上后继续发号, 超过时返回 ErrClockBackwards
+// 等待期间持有锁, d 不宜过大
+func WithMaxRollback(d time.Duration) WorkerOptFunc {
+	return func(opt *workerOpt) {
+		opt.maxRollback = d
+	}
+}
+
+// WithEpoch 起始时间戳(毫秒), 同一ID空间内的所有worker必须一致
+func WithEpoch(epoch int64) WorkerOptFunc {
+	return func(opt *workerOpt) {
+		opt.epoch = epoch
+	}
+}
+
+// WithDataCenterId 指定数据中心ID, 优先级高于环境变量和配置文件
+func WithDataCenterId(id int64) WorkerOptFunc {
+	return func(opt *workerOpt) {
+		opt.dataCenterID = id
+	}
+}
+
+// WithDataCenterName 按数据中心名称由分配器分配数据中心ID
+func WithDataCenterName(name string) WorkerOptFunc {
+	return func(opt *workerOpt) {
+		opt.dataCenterName = name
+	}
+}
+
+// WithLeaseCache 把租约缓存到本地文件 path, zk不可用时若缓存未超过 maxStaleness 则以缓存的workerId降级启动
+func WithLeaseCache(path string, maxStaleness time.Duration) WorkerOptFunc {
+	return func(opt *workerOpt) {
+		opt.leaseCachePath = path
+		opt.leaseMaxStaleness = maxStaleness
+	}
+}
+
+// WithHighWaterMark 把已使用的时间戳上界持久化到本地文件 path, 每次预留 reserve,
+// 重启后不会发出时间戳低于该上界的ID; reserve 越大写文件越少, 但重启后需要等待的时间也可能越长
+func WithHighWaterMark(path string, reserve time.Duration) WorkerOptFunc {
+	return func(opt *workerOpt) {
+		opt.hwmPath = path
+		opt.hwmReserve = reserve
+	}
+}
+
+// WithLogger 指定该 worker 及其 zkServer 使用的 Logger, 为空时使用全局的 base.DLogger;
+// zkServer 也可以通过 WithConnOpts(zkServer.WithLogger(l)) 单独指定
+func WithLogger(l base.Logger) WorkerOptFunc {
+	return func(opt *workerOpt) {
+		opt.logger = l
+	}
+}
+
+// NewSfWorker 启动失败时记录错误, 返回的 worker 在 NextID 等调用时返回该错误
+//
+// Deprecated: 启动失败时不会返回错误, 请使用 NewWorker
 func NewSfWorker(ofs ...zkServer.ConnOptFunc) *SfWorker {
 	//config.InitConfig("conf", "/conf.yaml")
 
-	opt := zkServer.DefaultOpt()
+	sfWorker, err := NewWorker(WithConnOpts(ofs...))
+	if err != nil {
+		base.ErrorF("NewWorker err: [%+v]", err)
+		return &SfWorker{stopCh: make(chan struct{}), closed: true, initErr: err}
+	}
+	return sfWorker
+}
+
+// NewWorker 创建worker, 与 NewSfWorker 不同的是启动失败时返回错误
+func NewWorker(ofs ...WorkerOptFunc) (_ *SfWorker, err error) {
+	opt := defaultWorkerOpt()
 	for _, op := range ofs {
 		op(opt)
 	}
+	if err := common.ValidateLayout(common.WorkerIDBits, common.DataCenterIDBits, common.SequenceBits,
+		opt.epoch, time.Now().UnixNano()/1e6); err != nil {
+		return nil, err
+	}
+	if opt.allocator != nil {
+		return newLeasedWorker(opt)
+	}
 
-	// start-monitor
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	sfWorker := newWorker(1)
+	dataCenterID, name, err := opt.resolveDataCenter()
+	if err != nil {
+		return nil, err
+	}
+	sfWorker := newWorker(dataCenterID, opt)
+	acquired := false
+	defer func() {
+		if err != nil {
+			sfWorker.abort(acquired)
+		}
+	}()
+	if name != "" {
+		if sfWorker.ServerType != common.ServerTypeZk {
+			return nil, common.InvalidDataCenterErr.WithTrueErr(fmt.Errorf("dataCenter name [%s] requires an allocator", name))
+		}
+		if sfWorker.dataCenterID, err = sfWorker.srv.zkSrv.AllocDataCenterId(name); err != nil {
+			return nil, err
+		}
+	}
 	workerId, err := sfWorker.getWorkerId()
+	if err != nil && sfWorker.ServerType == common.ServerTypeZk && !errors.Is(err, common.WorkerIdExhaustedErr) {
+		if err = sfWorker.startDegraded(opt, err); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	} else {
+		sfWorker.workerID = cast.ToInt64(workerId)
+		acquired = true
+		if err = sfWorker.checkClock(opt.maxClockSkew); err != nil {
+			return nil, err
+		}
+		if sfWorker.ServerType == common.ServerTypeZk {
+			sfWorker.startZkBackground()
+			sfWorker.leasedAt = sfWorker.getMilliSeconds()
+		}
+	}
+	if err = sfWorker.loadHighWaterMark(opt.hwmPath, opt.hwmReserve); err != nil {
+		return nil, err
+	}
+	if opt.leaseCachePath != "" {
+		sfWorker.leaseCachePath = opt.leaseCachePath
+		go sfWorker.persistLease(opt.leaseCachePath, opt.leaseCacheInterval)
+	}
+	if opt.watchConfig {
+		sfWorker.watchConfig()
+	}
+	return sfWorker, nil
+}
+
+// abort 启动失败时停止后台任务, 释放已占用的workerId并关闭zk会话, 避免临时节点在进程存活期间一直占用该ID
+func (w *SfWorker) abort(acquired bool) {
+	close(w.stopCh)
+	w.setDegraded(false)
+	if w.ServerType != common.ServerTypeZk {
+		return
+	}
+	if acquired {
+		workerId := int(w.workerID)
+		if err := w.srv.zkSrv.ReleaseWorkerId(workerId); err != nil {
+			w.log().WarningF("zkSrv.ReleaseWorkerId err:[%+v], workerId:[%d]", err, workerId)
+		}
+	}
+	w.srv.zkSrv.Shutdown()
+}
+
+// loadHighWaterMark 读取持久化的时间戳上界作为 lastStamp, 时钟追上之前 nextID 会拒绝发号; path 为空时不启用
+func (w *SfWorker) loadHighWaterMark(path string, reserve time.Duration) error {
+	if path == "" {
+		return nil
+	}
+	hwm := newHighWaterMark(path, reserve.Milliseconds())
+	hwm.logger = w.log()
+	stamp, err := hwm.load()
 	if err != nil {
-		base.ErrorF("sfWorker.getWorkerId err: [%+v]", err)
+		return err
+	}
+	if stamp > w.lastStamp {
+		w.log().WarningF("high-water mark %d is ahead of last stamp %d, refuse to generate until then", stamp, w.lastStamp)
+		w.lastStamp = stamp
+	}
+	w.hwm = hwm
+	return nil
+}
+
+// startZkBackground 持有租约后启动心跳及墓碑、审计记录的清理
+func (w *SfWorker) startZkBackground() {
+	w.srv.zkSrv.StartHeartbeat(int(w.workerID), w.heartbeatStamp)
+	w.srv.zkSrv.StartTombstoneCleaner()
+	w.srv.zkSrv.StartAuditCleaner(time.Hour)
+}
+
+// Status 返回worker当前状态, 降级模式下 Degraded 为true
+func (w *SfWorker) Status() WorkerStatus {
+	w.closeMu.RLock()
+	closed := w.closed
+	w.closeMu.RUnlock()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return WorkerStatus{
+		Closed:       closed,
+		WorkerID:     w.workerID,
+		DataCenterID: w.dataCenterID,
+		LastStamp:    w.lastStamp,
+		Degraded:     w.degraded,
+		LeaseLost:    w.leaseLost || w.leaseExpired(w.getMilliSeconds()),
+		LeasedAt:     w.leasedAt,
+	}
+}
+
+func (w *SfWorker) setDegraded(degraded bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.degraded == degraded {
+		return
+	}
+	w.degraded = degraded
+	if degraded {
+		degradedGauge.Add(1)
+	} else {
+		degradedGauge.Add(-1)
 	}
-	sfWorker.workerID = cast.ToInt64(workerId)
-	return sfWorker
-	//go SfWorker.monitor(errCh, c)
 }
 
 func (w *SfWorker) getWorkerId() (workerId int, err error) {
@@ -63,21 +335,26 @@ func (w *SfWorker) getWorkerId() (workerId int, err error) {
 		workerId = 1
 	} // initialization
 	if err != nil {
-		base.ErrorF("zkSrv.")
+		w.log().Warning("zkSrv.GetWorkerId fail", "err", err)
 	}
 	return
 }
 
 // newWorker 分布式情况下 通过外部配置文件或其他方式为个worker分配独立的id
 // eg: 静态配置文件、zk发号、redis发号
-func newWorker(dataCenterID int64) *SfWorker {
-	center := viper.Get("Center.Name")
+func newWorker(dataCenterID int64, wOpt *workerOpt) *SfWorker {
+	center := wOpt.center
 	errCh := make(chan error, 3)
 	internalSrv := InternalSrv{}
 	var srvType common.ServerType
 	switch center {
 	case "zk":
 		opt := zkServer.DefaultOpt()
+		zkServer.WithLogger(wOpt.logger)(opt)
+		for _, op := range wOpt.connOfs {
+			op(opt)
+		}
+		zkServer.WithDataCenterId(dataCenterID)(opt)
 		zkSrv := zkServer.NewZkServer(errCh, opt)
 		internalSrv.zkSrv = zkSrv
 		srvType = common.ServerTypeZk
@@ -95,65 +372,184 @@ func newWorker(dataCenterID int64) *SfWorker {
 		dataCenterID: dataCenterID,
 		srv:          internalSrv,
 		ServerType:   srvType,
+		stopCh:       make(chan struct{}),
+		logger:       wOpt.logger,
+		epoch:        wOpt.epoch,
+		maxRollback:  wOpt.maxRollback,
 	}
 }
 
+func (w *SfWorker) log() base.Logger {
+	return base.OrDefault(w.logger)
+}
+
+// checkClock 启动时的时钟检查(参考 Leaf):
+// 1. 本机时间落后于该workerId上次上报的时间戳时, 以其作为 lastStamp, 时钟追上之前 nextID 会拒绝发号
+// 2. 本机时间与其他存活worker上报时间的平均值相差超过 maxSkew 时拒绝启动
+func (w *SfWorker) checkClock(maxSkew time.Duration) error {
+	if w.ServerType != common.ServerTypeZk {
+		return nil
+	}
+	workerId := int(w.workerID)
+	lastStamp, err := w.srv.zkSrv.GetLastStamp(workerId)
+	if err != nil {
+		return err
+	}
+	now := w.getMilliSeconds()
+	if lastStamp > now {
+		w.log().WarningF("clock is behind last stamp of worker %d by %d ms, refuse to generate until then", workerId, lastStamp-now)
+		w.lastStamp = lastStamp
+	}
+
+	stamps, err := w.srv.zkSrv.GetLiveStamps(workerId)
+	if err != nil {
+		return err
+	}
+	if len(stamps) == 0 || maxSkew <= 0 {
+		return nil
+	}
+	var sum int64
+	for _, stamp := range stamps {
+		sum += stamp
+	}
+	skew := now - sum/int64(len(stamps))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxSkew.Milliseconds() {
+		return common.ClockSkewErr.WithTrueErr(fmt.Errorf("local clock differs from %d live workers by %d ms", len(stamps), skew))
+	}
+	return nil
+}
+
+// heartbeatStamp 上报给zk的时间戳: 取已发出ID的时间戳与当前时间的较大者
+func (w *SfWorker) heartbeatStamp() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.getMilliSeconds()
+	if w.lastStamp > now {
+		return w.lastStamp
+	}
+	return now
+}
+
+// now 检查租约后返回不小于 lastStamp 的当前时间戳; 时钟回拨不超过 maxRollback 时等待, 调用方持有 w.mu
+func (w *SfWorker) now() (int64, error) {
+	if w.leaseLost {
+		return 0, common.LeaseConflictErr
+	}
+	timeStamp := w.getMilliSeconds()
+	if w.leaseExpired(timeStamp) {
+		return 0, common.LeaseConflictErr.WithTrueErr(errors.New("lease expired"))
+	}
+	if timeStamp < w.lastStamp {
+		drift := time.Duration(w.lastStamp-timeStamp) * time.Millisecond
+		if drift > w.maxRollback {
+			return 0, &common.ClockBackwardsError{Drift: drift}
+		}
+		time.Sleep(drift)
+		if timeStamp = w.getMilliSeconds(); timeStamp < w.lastStamp {
+			return 0, &common.ClockBackwardsError{Drift: time.Duration(w.lastStamp-timeStamp) * time.Millisecond}
+		}
+	}
+	return timeStamp, nil
+}
+
 func (w *SfWorker) getMilliSeconds() int64 {
+	if w.clock != nil {
+		return w.clock()
+	}
 	return time.Now().UnixNano() / 1e6
 }
 
+// closedErr 已关闭时 NextID 等返回的错误, 调用方持有 closeMu
+func (w *SfWorker) closedErr() error {
+	if w.initErr != nil {
+		return w.initErr
+	}
+	return common.WorkerClosedErr
+}
+
 func (w *SfWorker) NextID() (uint64, error) {
+	w.closeMu.RLock()
+	defer w.closeMu.RUnlock()
+	if w.closed {
+		return 0, w.closedErr()
+	}
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
 	return w.nextID()
 }
 
-func (w *SfWorker) nextID() (uint64, error) {
-	timeStamp := w.getMilliSeconds()
-	if timeStamp < w.lastStamp {
-		return 0, errors.New("time is moving backwards,waiting until")
+// NextIDs 一次生成 n 个ID, 出错时返回错误和已生成的部分
+func (w *SfWorker) NextIDs(n int) ([]uint64, error) {
+	w.closeMu.RLock()
+	defer w.closeMu.RUnlock()
+	if w.closed {
+		return nil, w.closedErr()
 	}
 
-	if w.lastStamp == timeStamp {
-		w.sequence = (w.sequence + 1) & common.MaxSequence
-		if w.sequence == 0 {
-			for timeStamp <= w.lastStamp {
-				timeStamp = w.getMilliSeconds()
-			}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	ids := make([]uint64, 0, n)
+	for i := 0; i < n; i++ {
+		id, err := w.nextID()
+		if err != nil {
+			return ids, err
 		}
-	} else {
-		w.sequence = 0
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (w *SfWorker) nextID() (uint64, error) {
+	timeStamp, err := w.now()
+	if err != nil {
+		return 0, err
+	}
+	timeStamp, sequence, err := nextSequence(timeStamp, w.lastStamp, w.sequence, common.MaxSequence, func() (int64, error) {
+		return w.getMilliSeconds(), nil
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	w.lastStamp = timeStamp
-	id := ((timeStamp - common.Twepoch) << common.TimeLeft) |
+	if w.hwm != nil {
+		if err := w.hwm.ensure(timeStamp); err != nil {
+			return 0, err
+		}
+	}
+	w.lastStamp, w.sequence = timeStamp, sequence
+	id := ((timeStamp - w.epoch) << common.TimeLeft) |
 		(w.dataCenterID << common.DataLeft) |
-		(w.workerID << common.WorkLeft) | w.sequence
+		(w.workerID << common.WorkLeft) | sequence
 
 	return uint64(id), nil
 }
 
-// monitor -
-func (w *SfWorker) monitor(errCh chan error, sigCh chan os.Signal) {
-	for {
-		select {
-		case err := <-errCh:
-			base.WarningF("%v", err)
-		case s := <-sigCh:
-			base.InfoF("receive signal %v", s)
-			//app.GetApplication().Close()
-			success, err := w.srv.zkSrv.RemoveNode(common.WorkIdPathPrefix, cast.ToString(w.workerID))
-			if err != nil {
-				base.ErrorF("zkSrv.RemoveNode err:[%+v],  path:[%+v] , workerId:[%+v]", err, common.WorkIdPathPrefix, w.workerID)
-			}
-			if success {
-				base.InfoF("zkSrv.RemoveNode success:[%+v],  path:[%+v] , workerId:[%+v]", err, common.WorkIdPathPrefix, w.workerID)
-			} else {
-				base.InfoF("zkSrv.RemoveNode fail:[%+v],  path:[%+v] , workerId:[%+v]", err, common.WorkIdPathPrefix, w.workerID)
-			}
-
-			os.Exit(0)
+// nextSequence 上一个ID为 (lastStamp, sequence) 时, 取时间戳 timeStamp 上的下一个序列号:
+// 同一毫秒内加1, 用完时通过 next 等待下一毫秒, 时钟停滞超过 maxSequenceWait 时返回 SequenceExhaustedErr
+// 只返回新的时间戳和序列号, 调用方在发出ID时才保存, 出错时状态不变, 下次调用不会重复发出已用过的序列号
+func nextSequence(timeStamp, lastStamp, sequence, maxSequence int64, next func() (int64, error)) (int64, int64, error) {
+	if timeStamp != lastStamp {
+		return timeStamp, 0, nil
+	}
+	if sequence < maxSequence {
+		return timeStamp, sequence + 1, nil
+	}
+	deadline := time.Now().Add(maxSequenceWait)
+	for timeStamp <= lastStamp {
+		if time.Now().After(deadline) {
+			return 0, 0, common.SequenceExhaustedErr
+		}
+		var err error
+		if timeStamp, err = next(); err != nil {
+			return 0, 0, err
 		}
 	}
+	return timeStamp, 0, nil
 }
@@ -2,6 +2,8 @@ package snowFlake
 
 import (
 	"context"
+	"errors"
+	"github.com/lypee/snowFlake/common"
 	"github.com/lypee/snowFlake/server/zkServer"
 	"log"
 	"testing"
@@ -21,9 +23,7 @@ func BenchmarkSnowflake(b *testing.B) {
 	go countMap(sonCtx, ch)
 
 	wg.Add(nums)
-	errCh := make(chan error, 3)
-	sf := NewSfWorker(errCh,
-		zkServer.WithServers([]string{"43.138.36.75:2181"}))
+	sf := NewSfWorker(zkServer.WithServers([]string{"43.138.36.75:2181"}))
 	b.ResetTimer()
 	startTime := time.Now().Unix()
 	for i := 0; i < nums; i++ {
@@ -67,7 +67,86 @@ func countMap(ctx context.Context, ch chan uint64) {
 }
 
 func TestNewSfWorker(t *testing.T) {
-	errCh := make(chan error, 3)
-	sf := NewSfWorker(errCh, zkServer.WithServers([]string{"your host"}))
+	sf := NewSfWorker(zkServer.WithServers([]string{"your host"}))
 	sf.NextID()
 }
+
+func TestNewSfWorker_initErr(t *testing.T) {
+	t.Setenv(EnvDataCenterId, "not a number")
+	sf := NewSfWorker()
+	if sf == nil {
+		t.Fatal("NewSfWorker should not return nil")
+	}
+	if _, err := sf.NextID(); !errors.Is(err, common.InvalidDataCenterErr) {
+		t.Fatalf("NextID should return the init error, got %v", err)
+	}
+	if _, err := sf.NextIDs(2); !errors.Is(err, common.InvalidDataCenterErr) {
+		t.Fatalf("NextIDs should return the init error, got %v", err)
+	}
+	if !sf.Status().Closed {
+		t.Fatal("status should report closed")
+	}
+	if err := sf.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSfWorker_refuseBeforeLastStamp(t *testing.T) {
+	sf := newWorker(1, defaultWorkerOpt())
+	sf.lastStamp = sf.getMilliSeconds() + 50
+	_, err := sf.NextID()
+	var cb *ClockBackwardsError
+	if !errors.Is(err, ErrClockBackwards) || !errors.As(err, &cb) || cb.Drift <= 0 {
+		t.Fatalf("expected clock backwards error, got %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, err := sf.NextID(); err != nil {
+		t.Fatalf("NextID err: %v", err)
+	}
+	if sf.heartbeatStamp() < sf.lastStamp {
+		t.Fatal("heartbeat stamp should not be less than last stamp")
+	}
+}
+
+func TestSfWorker_sequenceExhausted(t *testing.T) {
+	sf := newWorker(1, defaultWorkerOpt())
+	now := sf.getMilliSeconds()
+	sf.clock = func() int64 { return now }
+
+	seen := map[uint64]bool{}
+	for i := int64(0); i <= common.MaxSequence; i++ {
+		id, err := sf.NextID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[id] = true
+	}
+	// 时钟停滞, 等待超时后不修改序列号, 之后的调用也不会重复发出
+	for i := 0; i < 2; i++ {
+		if id, err := sf.NextID(); !errors.Is(err, common.SequenceExhaustedErr) {
+			t.Fatalf("expected SequenceExhaustedErr, got %d %v", id, err)
+		}
+	}
+	sf.clock = func() int64 { return now + 1 }
+	id, err := sf.NextID()
+	if err != nil || seen[id] {
+		t.Fatalf("NextID after the clock moves on: %d %v", id, err)
+	}
+	if parts := sf.Decode(id); parts.Sequence != 0 {
+		t.Fatalf("sequence should restart at 0: %+v", parts)
+	}
+}
+
+func TestSfWorker_maxRollback(t *testing.T) {
+	opt := defaultWorkerOpt()
+	WithMaxRollback(50 * time.Millisecond)(opt)
+	sf := newWorker(1, opt)
+	sf.lastStamp = sf.getMilliSeconds() + 20
+	if _, err := sf.NextID(); err != nil {
+		t.Fatalf("rollback within tolerance should wait, got %v", err)
+	}
+	sf.lastStamp = sf.getMilliSeconds() + 100
+	if _, err := sf.NextID(); !errors.Is(err, ErrClockBackwards) {
+		t.Fatalf("expected clock backwards error, got %v", err)
+	}
+}